@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"fmt"
+	"proof/lsp"
+	"slices"
+)
+
+// The number of other occurrences of a typo listed as related information.
+const maxRelatedInformation = 10
+
+// clientDiagnostics adapts diagnostics to the capabilities of the client. It
+// links repeated typos in a document to each other if the client shows
+// related information.
+func (s *State) clientDiagnostics(uri string, diagnostics []lsp.Diagnostic) []lsp.Diagnostic {
+	related := s.Client.SupportsRelatedInformation()
+	occurrences := make(map[string][]lsp.Range)
+
+	if related {
+		for _, diagnostic := range diagnostics {
+			occurrences[diagnostic.Message] = append(occurrences[diagnostic.Message], diagnostic.Range)
+		}
+	}
+
+	adapted := make([]lsp.Diagnostic, len(diagnostics))
+
+	for i, diagnostic := range diagnostics {
+		diagnostic.RelatedInformation = nil
+
+		for _, rng := range occurrences[diagnostic.Message] {
+			if len(diagnostic.RelatedInformation) == maxRelatedInformation {
+				break
+			}
+
+			if rng == diagnostic.Range {
+				continue
+			}
+
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, lsp.DiagnosticRelatedInformation{
+				Location: lsp.Location{URI: uri, Range: rng},
+				Message:  fmt.Sprintf("Same typo on line %d", rng.Start.Line+1),
+			})
+		}
+
+		adapted[i] = diagnostic
+	}
+
+	return adapted
+}
+
+// codeActionResponse returns the actions as CodeAction literals if the
+// client supports them. Otherwise only the actions running a command can be
+// offered, which are returned as plain commands.
+func (s *State) codeActionResponse(request lsp.CodeActionRequest, actions []lsp.CodeAction) lsp.CodeActionResponse {
+	only := request.Params.Context.Only
+
+	if len(only) > 0 && !slices.Contains(only, lsp.QuickFix) {
+		actions = []lsp.CodeAction{}
+	}
+
+	if s.Client.SupportsCodeActionLiterals() {
+		return lsp.NewCodeActionResponse(request.ID, actions)
+	}
+
+	commands := []lsp.Command{}
+
+	for _, action := range actions {
+		if action.Command != nil && action.Edit == nil {
+			commands = append(commands, *action.Command)
+		}
+	}
+
+	return lsp.NewCommandsResponse(request.ID, commands)
+}
+
+// diagnosticsAt returns the diagnostics of proof sent by the client with a
+// code action request which cover the range of a word.
+func diagnosticsAt(diagnostics []lsp.Diagnostic, rng lsp.Range) []lsp.Diagnostic {
+	found := []lsp.Diagnostic{}
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Source == "proof" && diagnostic.Range == rng {
+			found = append(found, diagnostic)
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"proof/lsp"
+	"strings"
+	"testing"
+)
+
+func TestCodeActionsFollowClientCapabilities(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	path := filepath.Join(t.TempDir(), "dictionary.txt")
+	uri := "file:///test.txt"
+	position := lsp.Position{Line: 0, Character: 1}
+
+	codeAction := func(capabilities *lsp.CodeActionClientCapabilities) any {
+		t.Helper()
+
+		state := newTestState(t, "hello")
+		state.Client.TextDocument = &lsp.TextDocumentClientCapabilities{CodeAction: capabilities}
+		state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{DictionaryPath: &path}}, logger)
+		state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: "text", Text: "helo"}, logger)
+
+		return state.CodeAction(lsp.CodeActionRequest{
+			Params: lsp.CodeActionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        lsp.Range{Start: position, End: position},
+			},
+		}, uri, logger).Result
+	}
+
+	// Clients supporting literals get the edits as well as the commands
+	actions, ok := codeAction(&lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}}).([]lsp.CodeAction)
+
+	if !ok || len(actions) != 2 || actions[0].Command == nil || actions[1].Edit == nil || actions[1].Title != "Replace with 'hello'" {
+		t.Errorf("Expected code action literals, got %v", actions)
+	}
+
+	// Other clients only get the commands, which they can execute
+	for _, capabilities := range []*lsp.CodeActionClientCapabilities{nil, {}} {
+		commands, ok := codeAction(capabilities).([]lsp.Command)
+
+		if !ok || len(commands) != 1 || commands[0].Command != "proof.add_to_dictionary" {
+			t.Errorf("Expected only commands, got %v", commands)
+		}
+	}
+}
+
+func TestCodeActionsOnlyForCheckedWords(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		text       string
+		row        int
+		expected   bool
+	}{
+		{"go", "func helo() {}", 0, false},
+		{"go", "// helo", 0, true},
+		{"markdown", "```\nhelo\n```", 1, false},
+		{"markdown", "helo", 0, true},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "hello", "func")
+		state.Client.TextDocument = &lsp.TextDocumentClientCapabilities{
+			CodeAction: &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+		}
+
+		uri := "file:///test"
+		state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: c.languageID, Text: c.text}, logger)
+
+		line := strings.Split(c.text, "\n")[c.row]
+		position := lsp.Position{Line: c.row, Character: strings.Index(line, "helo") + 1}
+		actions := state.CodeAction(lsp.CodeActionRequest{
+			Params: lsp.CodeActionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        lsp.Range{Start: position, End: position},
+			},
+		}, uri, logger).Result.([]lsp.CodeAction)
+
+		if (len(actions) > 0) != c.expected {
+			t.Errorf("%s %q: Expected actions %v, got %v", c.languageID, c.text, c.expected, actions)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+)
+
+// FileReport holds the typos found in a file by CheckFiles.
+type FileReport struct {
+	Path        string
+	Diagnostics []lsp.Diagnostic
+}
+
+// CheckFiles checks the files below the paths like the documents opened by
+// a client, with the settings of the workspace folders and config files. A
+// path may also be a file. Files ignored by a .gitignore file, including the
+// ones above the paths, or excluded by the settings and binary files are
+// skipped. Only files with typos are
+// handed to report. It returns the number of files checked.
+func (s *State) CheckFiles(paths []string, report func(FileReport), logger *log.Logger) int {
+	files := []string{}
+
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+
+		walkFiles(path, s, logger, func(path string) {
+			files = append(files, path)
+		})
+	}
+
+	checked := 0
+
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+
+		if err != nil {
+			logger.Printf("Failed to read file: %s", err)
+			continue
+		}
+
+		if isBinary(content) {
+			continue
+		}
+
+		data := createDocumentData(lsp.TextDocumentItem{
+			URI:        pathToURI(path),
+			LanguageID: languageIDFromPath(path),
+			Text:       string(content),
+		})
+
+		s.settingsMutex.RLock()
+		diagnostics := getDiagnostics(data, s, logger)
+		s.settingsMutex.RUnlock()
+
+		checked++
+
+		if len(diagnostics) > 0 {
+			report(FileReport{Path: path, Diagnostics: diagnostics})
+		}
+	}
+
+	return checked
+}
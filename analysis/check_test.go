@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"testing"
+)
+
+func TestCheckFiles(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+
+	files := map[string]string{
+		".gitignore":      "build/\n",
+		".proof.toml":     "excludedFileTypes = [\"markdown\"]\n",
+		"src/a.go":        "package a\n\n// A wrold\nfunc a() {}\n",
+		"src/b.txt":       "a\n",
+		"build/c.txt":     "wrold\n",
+		"notes.md":        "wrold\n",
+		"src/binary.txt":  "wrold\x00",
+		"src/nested/d.go": "package nested // wrold\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := newTestState(t, "a", "package", "func", "nested")
+	state.Initialize(lsp.InitializeRequestParams{RootPath: root}, logger)
+
+	reports := map[string][]lsp.Diagnostic{}
+	checked := state.CheckFiles([]string{filepath.Join(root, "src"), filepath.Join(root, "build", "c.txt"), filepath.Join(root, "notes.md")}, func(report FileReport) {
+		rel, _ := filepath.Rel(root, report.Path)
+		reports[filepath.ToSlash(rel)] = report.Diagnostics
+	}, logger)
+
+	// Ignored files are only skipped below the paths, explicit paths are
+	// still checked unless the settings exclude them
+	if checked != 4 {
+		t.Errorf("Expected 4 files to be checked, got %d", checked)
+	}
+
+	if len(reports) != 3 || len(reports["src/a.go"]) != 1 || len(reports["src/nested/d.go"]) != 1 || len(reports["build/c.txt"]) != 1 {
+		t.Errorf("Unexpected reports %v", reports)
+	}
+
+	if start := reports["src/a.go"][0].Range.Start; start.Line != 2 || start.Character != 5 {
+		t.Errorf("Expected the typo in the comment, got %v", start)
+	}
+}
+
+func TestCheckFilesInSubdirectory(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+
+	files := map[string]string{
+		".git/HEAD":       "ref: refs/heads/main\n",
+		".gitignore":      "secret.md\nsub/generated/\n",
+		".proof.toml":     "ignoredWords = [\"zorblax\"]\n",
+		"sub/a.txt":       "a zorblax\n",
+		"sub/b.txt":       "a wrold\n",
+		"sub/secret.md":   "wrold\n",
+		"sub/generated/c": "wrold\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub := filepath.Join(root, "sub")
+
+	if folder := FindWorkspaceRoot(sub); folder != root {
+		t.Fatalf("Expected the repository root as the workspace folder, got %s", folder)
+	}
+
+	state := newTestState(t, "a")
+	state.Initialize(lsp.InitializeRequestParams{RootPath: FindWorkspaceRoot(sub)}, logger)
+
+	reports := map[string]int{}
+	checked := state.CheckFiles([]string{sub}, func(report FileReport) {
+		reports[filepath.Base(report.Path)] = len(report.Diagnostics)
+	}, logger)
+
+	if checked != 2 {
+		t.Errorf("Expected the ignored files to be skipped, checked %d files", checked)
+	}
+
+	if len(reports) != 1 || reports["b.txt"] != 1 {
+		t.Errorf("Expected only the typo in b.txt with the config file of the root, got %v", reports)
+	}
+}
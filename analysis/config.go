@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configFileNames are the names of the config files checked in with a
+// repository, in the order they are looked up in a directory. Only the
+// first one found in a directory is used.
+var configFileNames = []string{".proof.toml", "proof.json"}
+
+// ConfigFileNames returns the names of the config files, which are watched
+// for changes.
+func ConfigFileNames() []string {
+	return slices.Clone(configFileNames)
+}
+
+// configFile holds the settings of a config file in a directory of a
+// workspace folder. The settings of directories without a config file are
+// cached as nil.
+type configFile struct {
+	path     string
+	settings lsp.ProofSettings
+}
+
+// repositorySettings merges the settings of the config files in the
+// workspace folder containing a document, from the root of the folder down
+// to the directory of the document. Settings in the nearest file win.
+func (s *State) repositorySettings(uri string) lsp.ProofSettings {
+	settings := lsp.ProofSettings{}
+	folder, ok := s.workspaceFolder(uri)
+
+	if !ok {
+		return settings
+	}
+
+	path, _ := uriToPath(uri)
+	dirs := []string{}
+
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+
+		if dir == folder || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	slices.Reverse(dirs)
+
+	for _, dir := range dirs {
+		if config := s.configFile(dir); config != nil {
+			settings = settings.Merge(config.settings)
+		}
+	}
+
+	return settings
+}
+
+// configFile returns the config file of a directory, reading it the first
+// time it is needed.
+func (s *State) configFile(dir string) *configFile {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	if config, ok := s.configFiles[dir]; ok {
+		return config
+	}
+
+	var config *configFile
+
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		settings, err := readConfigFile(path)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			s.settingsErrors = append(s.settingsErrors, fmt.Errorf("invalid config file %s: %w", path, err))
+			break
+		}
+
+		config = &configFile{path: path, settings: settings}
+		break
+	}
+
+	s.configFiles[dir] = config
+
+	return config
+}
+
+// ConfigFilePaths returns the paths config files were looked up at for the
+// documents checked so far, whether they exist or not.
+func (s *State) ConfigFilePaths() []string {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	paths := []string{}
+
+	for dir := range s.configFiles {
+		for _, name := range configFileNames {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+
+	slices.Sort(paths)
+
+	return paths
+}
+
+func readConfigFile(path string) (lsp.ProofSettings, error) {
+	settings := lsp.ProofSettings{}
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return settings, err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		decoder := json.NewDecoder(strings.NewReader(string(content)))
+		decoder.DisallowUnknownFields()
+
+		return settings, decoder.Decode(&settings)
+	}
+
+	metadata, err := toml.Decode(string(content), &settings)
+
+	if err != nil {
+		return settings, err
+	}
+
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return settings, fmt.Errorf("unknown setting %s", undecoded[0])
+	}
+
+	return settings, nil
+}
+
+// ReloadConfigFile forgets the config file of the directory containing a
+// changed file if it is a config file. It reports if the config file was
+// changed, created or deleted.
+func (s *State) ReloadConfigFile(uri string, logger *log.Logger) bool {
+	path, ok := uriToPath(uri)
+
+	if !ok || !slices.Contains(configFileNames, filepath.Base(path)) {
+		return false
+	}
+
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	s.cacheMutex.Lock()
+	delete(s.configFiles, filepath.Dir(path))
+	s.cacheMutex.Unlock()
+
+	s.generation++
+	logger.Printf("Reloaded config file: %s", path)
+
+	return true
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"testing"
+)
+
+func TestConfigFiles(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(path string, content string) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(filepath.Join(root, ".proof.toml"), "ignoredWords = [\"jargn\"]\nseverity = \"warning\"\n")
+	write(filepath.Join(sub, "proof.json"), `{"ignoredWords": ["blorf"]}`)
+
+	state := newTestState(t, "a")
+	state.Initialize(lsp.InitializeRequestParams{
+		WorkspaceFolders: []lsp.WorkspaceFolder{{URI: pathToURI(root)}},
+	}, logger)
+
+	check := func(dir string) []lsp.Diagnostic {
+		data := createDocumentData(lsp.TextDocumentItem{URI: pathToURI(filepath.Join(dir, "a.txt")), LanguageID: "text", Text: "jargn blorf"})
+		return getDiagnostics(data, state, logger)
+	}
+
+	// The nearest config file wins, the severity is inherited from the root
+	diagnostics := check(root)
+
+	if len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 6 || *diagnostics[0].Severity != lsp.Warning {
+		t.Errorf("Expected only blorf to be reported as a warning, got %v", diagnostics)
+	}
+
+	if diagnostics := check(sub); len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 0 {
+		t.Errorf("Expected only jargn to be reported in the subdirectory, got %v", diagnostics)
+	}
+
+	// Changed config files are read again, invalid ones are reported
+	write(filepath.Join(sub, "proof.json"), `{"ignoredWordz": ["jargn"]}`)
+
+	if !state.ReloadConfigFile(pathToURI(filepath.Join(sub, "proof.json")), logger) {
+		t.Fatal("Expected the config file to be reloaded")
+	}
+
+	if state.ReloadConfigFile(pathToURI(filepath.Join(sub, "a.txt")), logger) {
+		t.Error("Expected other files not to be reloaded")
+	}
+
+	if diagnostics := check(sub); len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 6 {
+		t.Errorf("Expected the invalid config file to be skipped, got %v", diagnostics)
+	}
+
+	if errors := state.SettingsErrors(); len(errors) != 1 {
+		t.Errorf("Expected the invalid config file to be reported, got %v", errors)
+	}
+
+	// The config files take precedence over the settings of the client, even
+	// the ones scoped to the workspace folder
+	information := "information"
+	state.UpdateConfiguration(&lsp.ProofSettings{Severity: &information}, map[string]lsp.ProofSettings{
+		pathToURI(root): {IgnoredWords: []string{"blorf"}, Severity: &information},
+	}, logger)
+
+	diagnostics = check(root)
+
+	if len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 6 || *diagnostics[0].Severity != lsp.Warning {
+		t.Errorf("Expected the config file to win over the folder settings, got %v", diagnostics)
+	}
+}
@@ -0,0 +1,206 @@
+package analysis
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/agnivade/levenshtein"
+	"github.com/f1monkey/spellchecker"
+)
+
+// Dictionary is a source of known words. Words are looked up in lower case.
+type Dictionary interface {
+	Contains(word string) bool
+
+	// Suggest returns up to n known words close to the word, the closest
+	// first.
+	Suggest(word string, n int) []string
+}
+
+// WordSet is a dictionary held in memory, like the words of a setting or
+// the words declared in a document.
+type WordSet map[string]struct{}
+
+func (w WordSet) Contains(word string) bool {
+	_, ok := w[word]
+	return ok
+}
+
+// Suggest returns the words which are at most as far away from the word as
+// the spellchecker searches by default.
+func (w WordSet) Suggest(word string, n int) []string {
+	type candidate struct {
+		word     string
+		distance int
+	}
+
+	candidates := []candidate{}
+
+	for known := range w {
+		if distance := levenshtein.ComputeDistance(word, known); distance <= spellchecker.DefaultMaxErrors {
+			candidates = append(candidates, candidate{known, distance})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), strings.Compare(a.word, b.word))
+	})
+
+	suggestions := []string{}
+
+	for _, candidate := range candidates[:min(n, len(candidates))] {
+		suggestions = append(suggestions, candidate.word)
+	}
+
+	return suggestions
+}
+
+func wordSet(words []string) WordSet {
+	set := make(WordSet, len(words))
+
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+
+		if word != "" {
+			set[word] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// FileDictionary is a dictionary read from a file with one word per line.
+type FileDictionary struct {
+	// The path of the file, which doesn't exist until the first word is
+	// added. Dictionaries without a path are only held in memory.
+	Path string
+	WordSet
+}
+
+// ReadFileDictionary reads the dictionary in a file. The dictionary is empty
+// if the file can't be read.
+func ReadFileDictionary(path string) (FileDictionary, error) {
+	dictionary := FileDictionary{Path: path, WordSet: WordSet{}}
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return dictionary, err
+	}
+
+	dictionary.WordSet = wordSet(strings.Split(string(content), "\n"))
+
+	return dictionary, nil
+}
+
+// Add adds a word to the dictionary and appends it to its file.
+func (d FileDictionary) Add(word string) error {
+	d.WordSet[word] = struct{}{}
+
+	if d.Path == "" {
+		return nil
+	}
+
+	return appendWord(d.Path, word)
+}
+
+// Remove removes a word from the dictionary and every line with the word
+// from its file. The other lines are kept as they are.
+func (d FileDictionary) Remove(word string) error {
+	delete(d.WordSet, word)
+
+	if d.Path == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(d.Path)
+
+	if err != nil {
+		return err
+	}
+
+	lines := slices.DeleteFunc(strings.SplitAfter(string(content), "\n"), func(line string) bool {
+		return strings.ToLower(strings.TrimSpace(line)) == word
+	})
+
+	return os.WriteFile(d.Path, []byte(strings.Join(lines, "")), 0644)
+}
+
+// SpellcheckerDictionary is a dictionary indexed by a spellchecker, which
+// finds suggestions in large word lists quickly.
+type SpellcheckerDictionary struct {
+	*spellchecker.Spellchecker
+}
+
+func (d SpellcheckerDictionary) Contains(word string) bool {
+	return d.IsCorrect(word)
+}
+
+func (d SpellcheckerDictionary) Suggest(word string, n int) []string {
+	suggestions, err := d.Spellchecker.Suggest(word, n)
+
+	if err != nil {
+		return nil
+	}
+
+	return suggestions
+}
+
+// DictionaryLayer is a dictionary in a DictionaryStack. The name of the
+// layer is shown to users when it accepted a word. Words in a forbidden
+// layer are rejected even if a layer below knows them.
+type DictionaryLayer struct {
+	Name       string
+	Dictionary Dictionary
+	Forbidden  bool
+}
+
+// DictionaryStack combines dictionaries. Words are looked up from the first
+// layer to the last one, and the first layer which contains a word decides
+// about it. The stack is a dictionary itself.
+type DictionaryStack []DictionaryLayer
+
+// Lookup returns the layer which decided about a word. It reports if the
+// word is known, which it isn't if no layer contains it or if it is
+// forbidden.
+func (d DictionaryStack) Lookup(word string) (DictionaryLayer, bool) {
+	for _, layer := range d {
+		if layer.Dictionary != nil && layer.Dictionary.Contains(word) {
+			return layer, !layer.Forbidden
+		}
+	}
+
+	return DictionaryLayer{}, false
+}
+
+func (d DictionaryStack) Contains(word string) bool {
+	_, ok := d.Lookup(word)
+	return ok
+}
+
+// Suggest merges the suggestions of all layers, closest first. Forbidden
+// words are never suggested.
+func (d DictionaryStack) Suggest(word string, n int) []string {
+	suggestions := []string{}
+
+	for _, layer := range d {
+		if layer.Dictionary == nil || layer.Forbidden {
+			continue
+		}
+
+		for _, suggestion := range layer.Dictionary.Suggest(word, n) {
+			if !slices.Contains(suggestions, suggestion) && d.Contains(suggestion) {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+
+	// Layers rank their own suggestions best, so their order is kept for
+	// suggestions which are equally far away
+	slices.SortStableFunc(suggestions, func(a, b string) int {
+		return cmp.Compare(levenshtein.ComputeDistance(word, a), levenshtein.ComputeDistance(word, b))
+	})
+
+	return suggestions[:min(n, len(suggestions))]
+}
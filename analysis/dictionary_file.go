@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// The lines git writes around the sides of a merge conflict. The base side
+// of diff3 style conflicts starts with the base marker.
+const (
+	conflictStart     = "<<<<<<<"
+	conflictBase      = "|||||||"
+	conflictSeparator = "======="
+	conflictEnd       = ">>>>>>>"
+)
+
+// DictionaryProblem is a line of a dictionary file which doesn't work the
+// way it was likely meant to.
+type DictionaryProblem struct {
+	// The line of the problem, starting at 1.
+	Line    int
+	Message string
+}
+
+// ReadDictionaryFile returns the lines of a dictionary file without
+// surrounding whitespace. A missing file has no lines.
+func ReadDictionaryFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}, nil
+	}
+
+	return lines, nil
+}
+
+// WriteDictionaryFile replaces a dictionary file with the words, one per
+// line, creating the file and its directory if they don't exist.
+func WriteDictionaryFile(path string, words []string) error {
+	if err := ensureDir(path); err != nil {
+		return err
+	}
+
+	content := ""
+
+	for _, word := range words {
+		content += word + "\n"
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// DedupeWords removes empty lines and words which appeared before, ignoring
+// case, keeping the order of the words.
+func DedupeWords(lines []string) []string {
+	seen := WordSet{}
+	words := []string{}
+
+	for _, line := range lines {
+		word := strings.ToLower(line)
+
+		if word == "" || seen.Contains(word) {
+			continue
+		}
+
+		seen[word] = struct{}{}
+		words = append(words, line)
+	}
+
+	return words
+}
+
+// ResolveConflicts keeps the words of both sides of the merge conflicts in a
+// dictionary file and drops the conflict markers and the base sides.
+func ResolveConflicts(lines []string) []string {
+	resolved := []string{}
+	base := false
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, conflictBase):
+			base = true
+		case strings.HasPrefix(line, conflictSeparator):
+			base = false
+		case strings.HasPrefix(line, conflictStart), strings.HasPrefix(line, conflictEnd):
+		case !base:
+			resolved = append(resolved, line)
+		}
+	}
+
+	return resolved
+}
+
+// CheckDictionary finds merge conflicts, duplicates, lines which will never
+// match a word and words which are known anyway in the lines of a dictionary
+// file.
+func CheckDictionary(lines []string, known func(word string) bool) []DictionaryProblem {
+	problems := []DictionaryProblem{}
+	seen := map[string]int{}
+
+	for i, line := range lines {
+		word := strings.ToLower(line)
+		problem := ""
+
+		switch {
+		case strings.HasPrefix(line, conflictStart), strings.HasPrefix(line, conflictBase),
+			strings.HasPrefix(line, conflictSeparator), strings.HasPrefix(line, conflictEnd):
+			problem = "merge conflict marker"
+		case word == "":
+			continue
+		case seen[word] > 0:
+			problem = fmt.Sprintf("duplicate of line %d", seen[word])
+		case !isSingleWord(word):
+			problem = fmt.Sprintf("'%s' is not a single word and never matches", line)
+		case known(word):
+			problem = fmt.Sprintf("'%s' is in the built-in word list", line)
+		}
+
+		if seen[word] == 0 {
+			seen[word] = i + 1
+		}
+
+		if problem != "" {
+			problems = append(problems, DictionaryProblem{Line: i + 1, Message: problem})
+		}
+	}
+
+	return problems
+}
+
+// isSingleWord reports if a dictionary entry can match a word in a document,
+// which consists of letters only.
+func isSingleWord(entry string) bool {
+	words := splitIntoWords(0, 0, entry)
+
+	return len(words) == 1 && words[0].Text == entry
+}
@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestResolveConflicts(t *testing.T) {
+	lines := []string{
+		"alpha",
+		"<<<<<<< HEAD",
+		"beta",
+		"||||||| base",
+		"removed",
+		"=======",
+		"gamma",
+		">>>>>>> branch",
+		"Alpha",
+	}
+
+	resolved := DedupeWords(ResolveConflicts(lines))
+
+	if !slices.Equal(resolved, []string{"alpha", "beta", "gamma"}) {
+		t.Errorf("Expected both sides of the conflict without the base, got %v", resolved)
+	}
+}
+
+func TestCheckDictionary(t *testing.T) {
+	lines := []string{"jargn", "", "the", "Jargn", "can't", "=======", "blorf"}
+	problems := CheckDictionary(lines, func(word string) bool { return word == "the" })
+	expected := []DictionaryProblem{
+		{Line: 3, Message: "'the' is in the built-in word list"},
+		{Line: 4, Message: "duplicate of line 1"},
+		{Line: 5, Message: "'can't' is not a single word and never matches"},
+		{Line: 6, Message: "merge conflict marker"},
+	}
+
+	if !slices.Equal(problems, expected) {
+		t.Errorf("Expected %v, got %v", expected, problems)
+	}
+}
+
+func TestDictionaryFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dictionary.txt")
+
+	if lines, err := ReadDictionaryFile(path); err != nil || len(lines) != 0 {
+		t.Fatalf("Expected a missing file to have no lines, got %v (%v)", lines, err)
+	}
+
+	if err := WriteDictionaryFile(path, []string{"beta", "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines, err := ReadDictionaryFile(path); err != nil || !slices.Equal(lines, []string{"beta", "alpha"}) {
+		t.Errorf("Expected the written words, got %v (%v)", lines, err)
+	}
+}
@@ -0,0 +1,201 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDictionary knows its words and suggests them all in order.
+type fakeDictionary []string
+
+func (f fakeDictionary) Contains(word string) bool {
+	return slices.Contains(f, word)
+}
+
+func (f fakeDictionary) Suggest(_ string, n int) []string {
+	return f[:min(n, len(f))]
+}
+
+func TestDictionaryStack(t *testing.T) {
+	stack := DictionaryStack{
+		{Name: "forbidden", Dictionary: fakeDictionary{"colour"}, Forbidden: true},
+		{Name: "session", Dictionary: wordSet([]string{"Jargn"})},
+		{Name: "embedded", Dictionary: fakeDictionary{"color", "colour", "jargn", "jargon"}},
+	}
+
+	tests := []struct {
+		word  string
+		layer string
+		known bool
+	}{
+		{"jargn", "session", true},
+		{"jargon", "embedded", true},
+		{"colour", "forbidden", false},
+		{"blorf", "", false},
+	}
+
+	for _, test := range tests {
+		layer, known := stack.Lookup(test.word)
+
+		if layer.Name != test.layer || known != test.known {
+			t.Errorf("Lookup(%q) = %q, %v, expected %q, %v", test.word, layer.Name, known, test.layer, test.known)
+		}
+	}
+
+	// Suggestions are merged without duplicates and forbidden words, the
+	// closest first
+	if suggestions := stack.Suggest("jargin", 5); !slices.Equal(suggestions, []string{"jargn", "jargon", "color"}) {
+		t.Errorf("Unexpected suggestions %v", suggestions)
+	}
+
+	if suggestions := stack.Suggest("jargin", 1); !slices.Equal(suggestions, []string{"jargn"}) {
+		t.Errorf("Expected the suggestions to be limited, got %v", suggestions)
+	}
+}
+
+func TestForbiddenWords(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "color", "colour", "colors")
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{ForbiddenWords: []string{"Colour"}}}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{URI: "file:///a.txt", LanguageID: "text", Text: "color colour colours"})
+	diagnostics := getDiagnostics(data, state, logger)
+
+	if len(diagnostics) != 2 || diagnostics[0].Range.Start.Character != 6 || diagnostics[1].Range.Start.Character != 13 {
+		t.Errorf("Expected the forbidden word and its plural to be reported, got %v", diagnostics)
+	}
+
+	settings := state.settingsFor(data.URI)
+
+	if suggestions := state.suggest("colur", settings); slices.Contains(suggestions, "colour") {
+		t.Errorf("Expected the forbidden word not to be suggested, got %v", suggestions)
+	}
+}
+
+func TestRemoveAndUndoDictionaryChanges(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	path := filepath.Join(t.TempDir(), "dictionary.txt")
+
+	if err := os.WriteFile(path, []byte("Blorf\njargn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := newTestState(t, "a")
+	state.Client.TextDocument = &lsp.TextDocumentClientCapabilities{
+		CodeAction: &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+	}
+	rebuilt := make(chan struct{}, 1)
+	state.OnSpellcheckerRebuilt(func() { rebuilt <- struct{}{} })
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{DictionaryPath: &path}}, logger)
+
+	uri := "file:///test.txt"
+	state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: "text", Text: "a jargn"}, logger)
+
+	expectFile := func(expected string) {
+		t.Helper()
+
+		if content, err := os.ReadFile(path); err != nil || string(content) != expected {
+			t.Errorf("Expected the dictionary file to contain %q, got %q (%v)", expected, content, err)
+		}
+	}
+
+	waitForRebuild := func() {
+		t.Helper()
+
+		select {
+		case <-rebuilt:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the spellchecker to be rebuilt")
+		}
+	}
+
+	// Words only known from a dictionary can be removed
+	position := lsp.Position{Line: 0, Character: 3}
+	response := state.CodeAction(lsp.CodeActionRequest{
+		Params: lsp.CodeActionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Range:        lsp.Range{Start: position, End: position},
+		},
+	}, uri, logger)
+
+	actions := response.Result.([]lsp.CodeAction)
+
+	if len(actions) != 1 || actions[0].Title != "Remove 'jargn' from user dictionary" {
+		t.Fatalf("Expected a remove action, got %v", actions)
+	}
+
+	command := actions[0].Command
+
+	if state.ExecuteCommand(command.Command, command.Arguments, logger) != uri {
+		t.Fatal("Expected the word to be removed")
+	}
+
+	waitForRebuild()
+	expectFile("Blorf\n")
+
+	if diagnostics, _, _ := state.CheckDocument(uri, logger); len(diagnostics) != 1 {
+		t.Errorf("Expected the removed word to be reported, got %v", diagnostics)
+	}
+
+	// Undoing the removal adds the word again, and undoing the addition
+	// before removes the word which was added by hand
+	if state.ExecuteCommand("proof.add_to_dictionary", []string{uri, "Zorp"}, logger) != uri {
+		t.Fatal("Expected the word to be added")
+	}
+
+	expectFile("Blorf\nzorp\n")
+
+	if state.ExecuteCommand("proof.undo_last_dictionary_change", nil, logger) != uri {
+		t.Fatal("Expected the addition to be undone")
+	}
+
+	waitForRebuild()
+	expectFile("Blorf\n")
+
+	if state.ExecuteCommand("proof.undo_last_dictionary_change", nil, logger) != uri {
+		t.Fatal("Expected the removal to be undone")
+	}
+
+	expectFile("Blorf\njargn\n")
+
+	if diagnostics, _, _ := state.CheckDocument(uri, logger); len(diagnostics) != 0 {
+		t.Errorf("Expected the word to be known again, got %v", diagnostics)
+	}
+
+	if state.ExecuteCommand("proof.undo_last_dictionary_change", nil, logger) != "" {
+		t.Error("Expected nothing left to undo")
+	}
+}
+
+func TestStateDictionaries(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "a")
+	state.Dictionaries = []DictionaryLayer{
+		{Name: "medical dictionary", Dictionary: fakeDictionary{"jargn", "colour"}},
+	}
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{ForbiddenWords: []string{"colour"}}}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{URI: "file:///a.txt", LanguageID: "text", Text: "a jargn colour"})
+	diagnostics := getDiagnostics(data, state, logger)
+
+	if len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 8 {
+		t.Errorf("Expected only the forbidden word to be reported, got %v", diagnostics)
+	}
+
+	settings := state.settingsFor(data.URI)
+
+	if description := state.describeWord("jargn", settings); !strings.Contains(description, "Accepted by the medical dictionary.") {
+		t.Errorf("Expected the dictionary to be named, got %q", description)
+	}
+
+	if suggestions := state.suggest("jargon", settings); !slices.Equal(suggestions, []string{"jargn"}) {
+		t.Errorf("Expected the dictionary to be suggested from, got %v", suggestions)
+	}
+}
@@ -0,0 +1,225 @@
+package analysis
+
+import (
+	"log"
+	"proof/lsp"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// directivePattern matches the directives controlling which parts of a
+// document are checked. They have to follow the start of a line or the
+// start of a comment in one of the common comment syntaxes:
+//
+//	proof:ignore-next-line   the next line isn't checked
+//	proof:disable            lines aren't checked until the next proof:enable
+//	proof:enable
+//	proof:disable-file       the document isn't checked
+//	proof:words foo bar      the words are known in the document
+var directivePattern = regexp.MustCompile(`(?:^|//|/\*|\*|#|--|;|%|<!--|\{-|\(\*|\.\.)\s*proof:(ignore-next-line|disable-file|disable|enable|words)\b(.*)`)
+
+// commentClosers are removed from the words declared by proof:words.
+var commentClosers = []string{"*/", "-->", "-}", "*)"}
+
+// directives are the directives found in a document.
+type directives struct {
+	disabledFile bool
+	ignoredLines map[int]struct{}
+	words        []string
+}
+
+// parseDirectives finds the directives in a text. Matches at an offset for
+// which insideLiteral reports true, like in a string literal, aren't
+// directives.
+func parseDirectives(text string, insideLiteral func(offset int) bool) directives {
+	d := directives{ignoredLines: map[int]struct{}{}}
+
+	if !hasDirectives(text) {
+		return d
+	}
+
+	disabled := false
+	offset := 0
+
+	for row, line := range strings.Split(text, "\n") {
+		if disabled {
+			d.ignoredLines[row] = struct{}{}
+		}
+
+		start := offset
+		offset += len(line) + 1
+		index := directivePattern.FindStringSubmatchIndex(line)
+
+		if index == nil || insideLiteral(start+index[2]-len("proof:")) {
+			continue
+		}
+
+		switch line[index[2]:index[3]] {
+		case "ignore-next-line":
+			d.ignoredLines[row+1] = struct{}{}
+		case "disable":
+			disabled = true
+			d.ignoredLines[row] = struct{}{}
+		case "enable":
+			disabled = false
+		case "disable-file":
+			d.disabledFile = true
+		case "words":
+			words := line[index[4]:index[5]]
+
+			for _, closer := range commentClosers {
+				words = strings.ReplaceAll(words, closer, " ")
+			}
+
+			d.words = append(d.words, strings.Fields(words)...)
+		}
+	}
+
+	return d
+}
+
+// documentSettings resolves the settings of a document together with the
+// directives in it. The words declared by proof:words are known in the
+// document.
+func (s *State) documentSettings(document documentData, logger *log.Logger) (Settings, directives) {
+	settings := s.settingsFor(document.URI)
+	e := extraction{state: s, uri: document.URI, settings: settings, logger: logger}
+
+	// The regions are only extracted once a directive is found
+	var insideLiteral func(offset int) bool
+
+	d := parseDirectives(document.Text, func(offset int) bool {
+		if insideLiteral == nil {
+			insideLiteral = e.literals(document.LanguageID, document.Text)
+		}
+
+		return insideLiteral(offset)
+	})
+	settings.documentWords = wordSet(d.words)
+
+	return settings, d
+}
+
+func (d directives) ignores(row int) bool {
+	_, ok := d.ignoredLines[row]
+	return d.disabledFile || ok
+}
+
+// hasDirectives reports if a text may contain directives. Changes to such
+// documents may affect other lines, so they can't be checked line by line.
+func hasDirectives(text string) bool {
+	return strings.Contains(text, "proof:")
+}
+
+// commentSyntaxes are the comments used for directives inserted into
+// documents in languages without a lexer.
+var commentSyntaxes = map[string]delimiters{
+	"go":               {Open: "//"},
+	"java":             {Open: "//"},
+	"python":           {Open: "#"},
+	"css":              {Open: "/*", Close: "*/"},
+	"html":             {Open: "<!--", Close: "-->"},
+	"markdown":         {Open: "<!--", Close: "-->"},
+	"latex":            {Open: "%"},
+	"tex":              {Open: "%"},
+	"plaintex":         {Open: "%"},
+	"rst":              {Open: ".."},
+	"restructuredtext": {Open: ".."},
+	"asciidoc":         {Open: "//"},
+}
+
+// commentSyntax returns the delimiters of the comments in a language,
+// preferring line comments.
+func commentSyntax(languageID string) (delimiters, bool) {
+	if syntax, ok := commentSyntaxes[languageID]; ok {
+		return syntax, true
+	}
+
+	lexer, ok := lexers[languageID]
+
+	switch {
+	case !ok:
+		return delimiters{}, false
+	case len(lexer.lineComments) > 0:
+		return delimiters{Open: lexer.lineComments[0]}, true
+	case len(lexer.blockComments) > 0:
+		return lexer.blockComments[0], true
+	default:
+		return delimiters{}, false
+	}
+}
+
+// directiveComment returns a comment containing a directive in the syntax of
+// a language.
+func directiveComment(languageID string, directive string) (string, bool) {
+	syntax, ok := commentSyntax(languageID)
+
+	if !ok {
+		return "", false
+	}
+
+	comment := syntax.Open + " " + directive
+
+	if syntax.Close != "" {
+		comment += " " + syntax.Close
+	}
+
+	return comment, true
+}
+
+// ignoreLineDirective returns the line to insert above a line to ignore it
+// in a document of a language, indented like the line.
+func ignoreLineDirective(languageID string, line string) (string, bool) {
+	comment, ok := directiveComment(languageID, "proof:ignore-next-line")
+
+	if !ok {
+		return "", false
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	return indent + comment + "\n", true
+}
+
+// wordsDirective returns the edit appending a proof:words directive declaring
+// a word to the end of a document of a language.
+func wordsDirective(languageID string, text string, word string, encoding lsp.PositionEncodingKind) (lsp.TextEdit, bool) {
+	comment, ok := directiveComment(languageID, "proof:words "+word)
+
+	if !ok {
+		return lsp.TextEdit{}, false
+	}
+
+	row := strings.Count(text, "\n")
+	last := text[lineOffset(text, row):]
+	end := lsp.Position{Line: row, Character: encodedColumn(last, utf8.RuneCountInString(last), encoding)}
+
+	if last == "" {
+		return lsp.TextEdit{Range: lsp.Range{Start: end, End: end}, NewText: comment + "\n"}, true
+	}
+
+	return lsp.TextEdit{Range: lsp.Range{Start: end, End: end}, NewText: "\n" + comment}, true
+}
+
+// literals returns a function reporting if an offset of a text in a language
+// lies inside a region which is neither a comment nor prose, like a string
+// literal spanning several lines. Lines inserted there would change the
+// literal and directives there are part of the literal.
+func (e extraction) literals(languageID string, text string) func(offset int) bool {
+	regions, ok := e.regions(languageID, text)
+
+	return func(offset int) bool {
+		if !ok {
+			return false
+		}
+
+		for _, r := range regions {
+			if r.Start < offset && offset < r.End && r.Kind != regionComment && r.Kind != regionText {
+				return true
+			}
+		}
+
+		return false
+	}
+}
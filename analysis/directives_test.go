@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"strings"
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		text       string
+		expected   []string
+	}{
+		{
+			"go",
+			"// proof:ignore-next-line\n// frst\n// scnd\n/* proof:words thrd fourthh */\n// thrd fourthh\n",
+			[]string{"scnd"},
+		},
+		{
+			"python",
+			"# frst\n# proof:disable\n# scnd\n# thrd\n# proof:enable\n# fourthh\n",
+			[]string{"frst", "fourthh"},
+		},
+		{
+			"markdown",
+			"Frst\n\n<!-- proof:disable-file -->\n",
+			[]string{},
+		},
+		{
+			"text",
+			"frst proof:ignore-next-line\nscnd\n",
+			[]string{"frst", "scnd"},
+		},
+		{
+			"python",
+			"x = \"# proof:disable-file\"\ny = '''\nproof:ignore-next-line\n'''\n# frst\n",
+			[]string{"frst"},
+		},
+		{
+			"go",
+			"var x = \"// proof:words frst\" // frst\n",
+			[]string{"frst", "frst"},
+		},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "proof", "ignore", "next", "line", "disable", "enable", "file", "words")
+		data := createDocumentData(lsp.TextDocumentItem{URI: "file:///test", LanguageID: c.languageID, Text: c.text})
+		diagnostics := getDiagnostics(data, state, logger)
+
+		if len(diagnostics) != len(c.expected) {
+			t.Errorf("%s: Expected %d diagnostics, got %v", c.languageID, len(c.expected), diagnostics)
+			continue
+		}
+
+		for i, word := range c.expected {
+			if diagnostics[i].Message != "Typo in word: "+word {
+				t.Errorf("%s: Expected typo %s, got %s", c.languageID, word, diagnostics[i].Message)
+			}
+		}
+	}
+}
+
+func TestIgnoreOnThisLineCodeAction(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		line       string
+		directive  string
+	}{
+		{"go", "\t// a typpo", "\t// proof:ignore-next-line\n"},
+		{"python", "    x = 'typpo'", "    # proof:ignore-next-line\n"},
+		{"html", "<p>typpo</p>", "<!-- proof:ignore-next-line -->\n"},
+		{"css", "/* typpo */", "/* proof:ignore-next-line */\n"},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "a", "x", "p")
+		state.Client.TextDocument = &lsp.TextDocumentClientCapabilities{
+			CodeAction: &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+		}
+
+		uri := "file:///test"
+		state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: c.languageID, Text: "first\n" + c.line}, logger)
+
+		position := lsp.Position{Line: 1, Character: strings.Index(c.line, "typpo") + 1}
+		response := state.CodeAction(lsp.CodeActionRequest{
+			Params: lsp.CodeActionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        lsp.Range{Start: position, End: position},
+			},
+		}, uri, logger)
+
+		actions := response.Result.([]lsp.CodeAction)
+		var ignore *lsp.CodeAction
+
+		for i := range actions {
+			if actions[i].Title == "Ignore on this line" {
+				ignore = &actions[i]
+			}
+		}
+
+		if ignore == nil {
+			t.Errorf("%s: Expected an ignore action, got %v", c.languageID, actions)
+			continue
+		}
+
+		edit := ignore.Edit.Changes[uri][0]
+
+		if edit.NewText != c.directive || edit.Range.Start.Line != 1 || edit.Range.Start.Character != 0 {
+			t.Errorf("%s: Expected to insert %q above the line, got %v", c.languageID, c.directive, edit)
+		}
+	}
+}
+
+func TestIgnoreWordInsteadOfLineInStrings(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		text       string
+		edit       lsp.TextEdit
+	}{
+		{
+			"python",
+			"def f():\n    \"\"\"Docs\n    typpo\n    \"\"\"\n",
+			lsp.TextEdit{Range: lineRange(4, 0, 0), NewText: "# proof:words typpo\n"},
+		},
+		{
+			"go",
+			"package a\n\nvar x = `raw\ntyppo`",
+			lsp.TextEdit{Range: lineRange(3, 6, 6), NewText: "\n// proof:words typpo"},
+		},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "a", "x", "def", "f", "docs", "raw", "package", "var")
+		state.Client.TextDocument = &lsp.TextDocumentClientCapabilities{
+			CodeAction: &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+		}
+
+		uri := "file:///test"
+		state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: c.languageID, Text: c.text}, logger)
+
+		row := strings.Count(c.text[:strings.Index(c.text, "typpo")], "\n")
+		position := lsp.Position{Line: row, Character: 1 + strings.Index(strings.Split(c.text, "\n")[row], "typpo")}
+		response := state.CodeAction(lsp.CodeActionRequest{
+			Params: lsp.CodeActionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        lsp.Range{Start: position, End: position},
+			},
+		}, uri, logger)
+
+		var ignore *lsp.CodeAction
+
+		for _, action := range response.Result.([]lsp.CodeAction) {
+			if action.Title == "Ignore on this line" {
+				t.Errorf("%s: Expected no directive to be inserted into the string", c.languageID)
+			}
+
+			if action.Title == "Ignore 'typpo' in this document" {
+				ignore = &action
+			}
+		}
+
+		if ignore == nil {
+			t.Errorf("%s: Expected an action to ignore the word", c.languageID)
+			continue
+		}
+
+		if edit := ignore.Edit.Changes[uri][0]; edit != c.edit {
+			t.Errorf("%s: Expected %v, got %v", c.languageID, c.edit, edit)
+		}
+	}
+}
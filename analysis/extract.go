@@ -0,0 +1,297 @@
+package analysis
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type regionKind string
+
+const (
+	regionComment    regionKind = "comments"
+	regionString     regionKind = "strings"
+	regionIdentifier regionKind = "identifiers"
+
+	// Prose in markup languages.
+	regionText regionKind = "text"
+
+	// Regions selected by captures of user defined queries which don't
+	// have the name of one of the other kinds. They are always checked.
+	regionCustom regionKind = ""
+)
+
+// defaultRegions are checked in languages which have an extractor unless
+// the checkedRegions setting says otherwise.
+var defaultRegions = []regionKind{regionComment, regionString, regionText}
+
+// region is a part of a document between the byte offsets Start and End.
+type region struct {
+	Kind  regionKind
+	Start int
+	End   int
+}
+
+// span is a part of a text between the byte offsets start and end.
+type span struct {
+	start int
+	end   int
+}
+
+// extractor finds the regions of a document which may be checked.
+type extractor func(text string, e extraction) []region
+
+// extractors maps language identifiers to the extractor used for them.
+// Documents in other languages are checked entirely.
+var extractors = map[string]extractor{}
+
+func init() {
+	extractors["go"] = extractGo
+	extractors["markdown"] = extractMarkdown
+	extractors["rst"] = extractRst
+	extractors["restructuredtext"] = extractRst
+	extractors["asciidoc"] = extractAsciidoc
+	extractors["yaml"] = extractYaml
+
+	for _, languageID := range []string{"latex", "tex", "plaintex"} {
+		extractors[languageID] = extractLatex
+	}
+
+	for languageID, lexer := range lexers {
+		extractors[languageID] = lexer.extract
+	}
+}
+
+// extraction holds what extractors need to know about the document they
+// extract regions from, for example to check embedded code.
+type extraction struct {
+	state    *State
+	uri      string
+	settings Settings
+	logger   *log.Logger
+}
+
+// checkedText returns the text of a document with everything that should not
+// be checked in its language replaced by spaces. Line breaks and the number
+// of runes on each line are kept, so columns in the checked text are the
+// same as in the document. It reports false if the whole text is checked.
+func (s *State) checkedText(document documentData, settings Settings, logger *log.Logger) (string, bool) {
+	e := extraction{state: s, uri: document.URI, settings: settings, logger: logger}
+	regions, ok := e.checkedRegions(document.LanguageID, document.Text)
+
+	if !ok {
+		return document.Text, false
+	}
+
+	return maskText(document.Text, regions), true
+}
+
+// checkedLine returns a line of a document the way it is checked, with the
+// parts which aren't checked in its language and the skipped tokens replaced
+// by spaces. It reports false if the line is ignored by a directive.
+func (s *State) checkedLine(document documentData, settings Settings, directives directives, row int, logger *log.Logger) (string, bool) {
+	if directives.ignores(row) {
+		return "", false
+	}
+
+	text, _ := s.checkedText(document, settings, logger)
+	line, ok := lineAt(text, row)
+
+	if !ok {
+		return "", false
+	}
+
+	return skipTokens(line, settings), true
+}
+
+// checkedRegions returns the regions of a text in a language which are
+// checked with the settings, sorted by their start offset. It reports false
+// if the language has no extractor.
+func (e extraction) checkedRegions(languageID string, text string) ([]region, bool) {
+	regions, ok := e.regions(languageID, text)
+
+	if !ok {
+		return nil, false
+	}
+
+	kinds := e.settings.regionsFor(languageID)
+	regions = slices.DeleteFunc(regions, func(r region) bool {
+		return r.Kind != regionCustom && !slices.Contains(kinds, r.Kind)
+	})
+	regions = withoutEscapes(text, regions)
+
+	slices.SortFunc(regions, func(a, b region) int {
+		return a.Start - b.Start
+	})
+
+	return regions, true
+}
+
+// regions selects the regions of a text by a user defined tree-sitter query
+// if there is one for the language, then by the extractor of the language
+// and last by the default tree-sitter query of the language.
+func (e extraction) regions(languageID string, text string) ([]region, bool) {
+	if path, found := userQuery(e.state.queryDirs(e.uri), languageID); found && hasGrammar(languageID) {
+		regions, err := extractTreeSitter(languageID, path, text)
+
+		if err == nil {
+			return regions, true
+		}
+
+		e.logger.Printf("Failed to use query %s: %s", path, err)
+	}
+
+	if extract, found := extractors[languageID]; found {
+		return extract(text, e), true
+	}
+
+	if hasGrammar(languageID) {
+		regions, err := extractTreeSitter(languageID, "", text)
+		return regions, err == nil
+	}
+
+	return nil, false
+}
+
+// hasExtractor reports if only parts of documents in a language are checked.
+// Changes to such documents may affect the regions of following lines, so
+// they can't be checked line by line.
+func hasExtractor(languageID string) bool {
+	_, ok := extractors[languageID]
+	return ok || hasGrammar(languageID)
+}
+
+func hasGrammar(languageID string) bool {
+	_, ok := grammars[languageID]
+	return ok
+}
+
+// escapeSequence matches the escape sequences of string literals, like \n,
+// \x41 or \u{1F600}, which would otherwise be joined with the following word.
+var escapeSequence = regexp.MustCompile(`\\(?:x[0-9a-fA-F]{1,2}|u\{[0-9a-fA-F]*\}|u[0-9a-fA-F]{4}|U[0-9a-fA-F]{8}|[0-7]{1,3}|.)`)
+
+// withoutEscapes splits the string regions at their escape sequences.
+func withoutEscapes(text string, regions []region) []region {
+	split := []region{}
+
+	for _, r := range regions {
+		if r.Kind != regionString {
+			split = append(split, r)
+			continue
+		}
+
+		start := r.Start
+
+		for _, match := range escapeSequence.FindAllStringIndex(text[r.Start:r.End], -1) {
+			split = append(split, region{Kind: r.Kind, Start: start, End: r.Start + match[0]})
+			start = r.Start + match[1]
+		}
+
+		split = append(split, region{Kind: r.Kind, Start: start, End: r.End})
+	}
+
+	return split
+}
+
+// maskText replaces every rune outside of the regions with a space. The
+// regions have to be sorted by their start offset but may overlap.
+func maskText(text string, regions []region) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	next := 0
+
+	for offset, r := range text {
+		for next < len(regions) && regions[next].End <= offset {
+			next++
+		}
+
+		inside := next < len(regions) && regions[next].Start <= offset
+
+		if inside || r == '\n' {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteByte(' ')
+		}
+	}
+
+	return builder.String()
+}
+
+// regionsFor returns the kinds of regions which are checked in a language.
+func (settings Settings) regionsFor(languageID string) []regionKind {
+	names, ok := settings.CheckedRegions[languageID]
+
+	if !ok {
+		names, ok = settings.CheckedRegions["*"]
+	}
+
+	if !ok {
+		return defaultRegions
+	}
+
+	kinds := []regionKind{}
+
+	for _, name := range names {
+		kinds = append(kinds, regionKind(name))
+	}
+
+	return kinds
+}
+
+// complement returns the text regions between the skipped spans.
+func complement(text string, skipped []span) []region {
+	slices.SortFunc(skipped, func(a, b span) int {
+		return a.start - b.start
+	})
+
+	regions := []region{}
+	offset := 0
+
+	for _, s := range skipped {
+		if s.start > offset {
+			regions = append(regions, region{Kind: regionText, Start: offset, End: s.start})
+		}
+
+		offset = max(offset, s.end)
+	}
+
+	if offset < len(text) {
+		regions = append(regions, region{Kind: regionText, Start: offset, End: len(text)})
+	}
+
+	return regions
+}
+
+// closingBracket returns the offset after the bracket closing the one text
+// starts with, or the length of the text if it isn't closed.
+func closingBracket(text string) int {
+	open, close := text[0], byte(')')
+
+	switch open {
+	case '[':
+		close = ']'
+	case '{':
+		close = '}'
+	}
+
+	depth := 0
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(text)
+}
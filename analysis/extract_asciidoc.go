@@ -0,0 +1,192 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	asciidocAttributeEntry = regexp.MustCompile(`^:!?[\w][\w-]*!?:(\s|$)`)
+	asciidocBlockAttribute = regexp.MustCompile(`^\[.*\]$`)
+	asciidocDelimiter      = regexp.MustCompile(`^(-{4,}|\.{4,}|\+{4,}|/{4,}|={4,}|\*{4,}|_{4,}|` + "`{3,}" + `)$`)
+	asciidocBlockMacro     = regexp.MustCompile(`^([a-z]+)::[^\s\[]*\[`)
+	asciidocInlineMacro    = regexp.MustCompile(`^([a-z]+):[^\s\[]*\[`)
+	asciidocURL            = regexp.MustCompile(`^(https?|ftp|irc|file)://[^\s\[]*`)
+	asciidocAttributeRef   = regexp.MustCompile(`^\{[\w-]+\}`)
+	asciidocAnchor         = regexp.MustCompile(`^\[\[[^\]]*\]\]`)
+)
+
+// asciidocInlineMacros are inline macros whose target is skipped. The
+// text in brackets is only checked for the ones mapped to true.
+var asciidocInlineMacros = map[string]bool{
+	"link":       true,
+	"xref":       true,
+	"image":      true,
+	"footnote":   true,
+	"mailto":     true,
+	"anchor":     false,
+	"icon":       false,
+	"kbd":        false,
+	"btn":        false,
+	"menu":       false,
+	"pass":       false,
+	"stem":       false,
+	"latexmath":  false,
+	"asciimath":  false,
+	"indexterm":  false,
+	"indexterm2": false,
+}
+
+// asciidocProseMacros are block macros whose text in brackets is prose,
+// like the alt text of images. The whole line of other block macros like
+// include:: is skipped.
+var asciidocProseMacros = map[string]bool{
+	"image": true,
+}
+
+// asciidocRawDelimiters are the delimiters of listing, literal and
+// passthrough blocks, which aren't checked, and of comment blocks, which are
+// checked as comments.
+var asciidocRawDelimiters = map[byte]bool{'-': true, '.': true, '+': true, '`': true, '/': true}
+
+// extractAsciidoc selects the prose and comments of an AsciiDoc document.
+// Attribute entries, block attributes, listing, literal and passthrough
+// blocks, monospace text, macro targets, cross reference ids and attribute
+// references are skipped.
+func extractAsciidoc(text string, _ extraction) []region {
+	skipped := []span{}
+	comments := []region{}
+	delimiter := ""
+	offset := 0
+
+	for offset < len(text) {
+		end := lineEnd(text, offset)
+		line := strings.TrimRight(text[offset:end], "\r")
+		trimmed := strings.TrimRight(line, " \t")
+
+		switch {
+		case delimiter != "":
+			skipped = append(skipped, span{offset, end})
+
+			if trimmed == delimiter {
+				delimiter = ""
+			} else if delimiter[0] == '/' {
+				comments = append(comments, region{Kind: regionComment, Start: offset, End: offset + len(line)})
+			}
+
+		case asciidocDelimiter.MatchString(trimmed):
+			skipped = append(skipped, span{offset, end})
+
+			if asciidocRawDelimiters[trimmed[0]] {
+				delimiter = trimmed
+			}
+
+		case strings.HasPrefix(line, "//"):
+			skipped = append(skipped, span{offset, end})
+			comments = append(comments, region{Kind: regionComment, Start: offset + 2, End: offset + len(line)})
+
+		case asciidocAttributeEntry.MatchString(line), asciidocBlockAttribute.MatchString(trimmed):
+			skipped = append(skipped, span{offset, end})
+
+		case asciidocBlockMacro.MatchString(line):
+			match := asciidocBlockMacro.FindStringSubmatch(line)
+
+			if !asciidocProseMacros[match[1]] {
+				skipped = append(skipped, span{offset, end})
+				break
+			}
+
+			skipped = append(skipped, span{offset, offset + len(match[0])})
+			asciidocInline(line[len(match[0]):], offset+len(match[0]), &skipped)
+
+		default:
+			asciidocInline(line, offset, &skipped)
+		}
+
+		offset = end + 1
+	}
+
+	return append(complement(text, skipped), comments...)
+}
+
+// asciidocInline adds the parts of a line of prose which aren't checked to
+// skipped.
+func asciidocInline(line string, offset int, skipped *[]span) {
+	skip := func(start, end int) {
+		*skipped = append(*skipped, span{offset + start, offset + end})
+	}
+
+	i := 0
+
+	for i < len(line) {
+		rest := line[i:]
+		wordStart := i == 0 || !isASCIILetter(line[i-1])
+
+		switch {
+		case rest[0] == '\\':
+			i += 2
+
+		case rest[0] == '`':
+			run := rest[:len(rest)-len(strings.TrimLeft(rest, "`"))]
+			close := strings.Index(rest[len(run):], run)
+
+			if close == -1 {
+				i += len(run)
+				break
+			}
+
+			end := i + len(run) + close + len(run)
+			skip(i, end)
+			i = end
+
+		case strings.HasPrefix(rest, "<<"):
+			end := closingIndex(line, i+2, ">>")
+
+			// Only the text after the id of a cross reference is checked
+			if comma := strings.IndexByte(line[i:end], ','); comma != -1 {
+				skip(i, i+comma+1)
+				skip(end-2, end)
+			} else {
+				skip(i, end)
+			}
+
+			i = end
+
+		case asciidocAnchor.MatchString(rest):
+			end := i + len(asciidocAnchor.FindString(rest))
+			skip(i, end)
+			i = end
+
+		case asciidocAttributeRef.MatchString(rest):
+			end := i + len(asciidocAttributeRef.FindString(rest))
+			skip(i, end)
+			i = end
+
+		case wordStart && asciidocURL.MatchString(rest):
+			end := i + len(asciidocURL.FindString(rest))
+			skip(i, end)
+			i = end
+
+		case wordStart && asciidocInlineMacro.MatchString(rest):
+			match := asciidocInlineMacro.FindStringSubmatch(rest)
+			checked, ok := asciidocInlineMacros[match[1]]
+
+			if !ok {
+				i++
+				break
+			}
+
+			end := i + len(match[0])
+
+			if !checked {
+				end = i + closingBracket(rest[len(match[0])-1:]) + len(match[0]) - 1
+			}
+
+			skip(i, end)
+			i = end
+
+		default:
+			i++
+		}
+	}
+}
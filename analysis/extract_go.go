@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// extractGo uses the scanner of the go standard library to find comments,
+// string literals and identifiers in go code.
+func extractGo(text string, _ extraction) []region {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(text))
+	source := []byte(text)
+
+	var s scanner.Scanner
+	s.Init(file, source, func(token.Position, string) {}, scanner.ScanComments)
+
+	regions := []region{}
+
+	for {
+		pos, tok, literal := s.Scan()
+
+		if tok == token.EOF {
+			break
+		}
+
+		start := file.Offset(pos)
+
+		// The scanner strips carriage returns from comments and raw
+		// strings, so their end is searched in the text instead
+		switch tok {
+		case token.COMMENT:
+			regions = append(regions, region{Kind: regionComment, Start: start, End: commentEnd(text, start)})
+
+		case token.STRING, token.CHAR:
+			end := start + len(literal)
+
+			if strings.HasPrefix(literal, "`") {
+				end = closingIndex(text, start+1, "`")
+			}
+
+			regions = append(regions, region{Kind: regionString, Start: start, End: end})
+
+		case token.IDENT:
+			regions = append(regions, region{Kind: regionIdentifier, Start: start, End: start + len(literal)})
+		}
+	}
+
+	return regions
+}
+
+func commentEnd(text string, start int) int {
+	if strings.HasPrefix(text[start:], "//") {
+		return lineEnd(text, start)
+	}
+
+	return closingIndex(text, start+2, "*/")
+}
+
+// lineEnd returns the offset of the line break ending the line containing
+// start, or the length of the text on the last line.
+func lineEnd(text string, start int) int {
+	index := strings.IndexByte(text[start:], '\n')
+
+	if index == -1 {
+		return len(text)
+	}
+
+	return start + index
+}
+
+// closingIndex returns the offset after the first occurrence of delimiter at
+// or after start, or the length of the text if there is none.
+func closingIndex(text string, start int, delimiter string) int {
+	index := strings.Index(text[start:], delimiter)
+
+	if index == -1 {
+		return len(text)
+	}
+
+	return start + index + len(delimiter)
+}
@@ -0,0 +1,189 @@
+package analysis
+
+import "strings"
+
+// latexArguments maps commands whose arguments aren't prose to the number of
+// braced arguments skipped after them, or -1 to skip all of them. Optional
+// arguments in brackets are skipped as well.
+var latexArguments = map[string]int{
+	"begin":             -1,
+	"end":               1,
+	"documentclass":     1,
+	"usepackage":        1,
+	"RequirePackage":    1,
+	"input":             1,
+	"include":           1,
+	"includeonly":       1,
+	"includegraphics":   1,
+	"graphicspath":      1,
+	"lstinputlisting":   1,
+	"inputminted":       -1,
+	"bibliography":      1,
+	"bibliographystyle": 1,
+	"addbibresource":    1,
+	"label":             1,
+	"ref":               1,
+	"eqref":             1,
+	"pageref":           1,
+	"autoref":           1,
+	"nameref":           1,
+	"cref":              1,
+	"Cref":              1,
+	"cite":              1,
+	"citep":             1,
+	"citet":             1,
+	"citeauthor":        1,
+	"nocite":            1,
+	"url":               1,
+	"href":              1,
+	"hyperref":          0,
+	"newcommand":        1,
+	"renewcommand":      1,
+	"providecommand":    1,
+	"newenvironment":    1,
+	"renewenvironment":  1,
+	"setlength":         -1,
+	"addtolength":       -1,
+	"setcounter":        -1,
+	"addtocounter":      -1,
+	"hspace":            1,
+	"vspace":            1,
+	"color":             1,
+	"textcolor":         1,
+	"colorbox":          1,
+	"definecolor":       -1,
+	"pagestyle":         1,
+	"thispagestyle":     1,
+	"pagenumbering":     1,
+}
+
+// latexRawEnvironments are environments containing math or code.
+var latexRawEnvironments = map[string]bool{
+	"math":        true,
+	"displaymath": true,
+	"equation":    true,
+	"align":       true,
+	"alignat":     true,
+	"flalign":     true,
+	"gather":      true,
+	"multline":    true,
+	"eqnarray":    true,
+	"verbatim":    true,
+	"Verbatim":    true,
+	"lstlisting":  true,
+	"minted":      true,
+	"comment":     true,
+	"tikzpicture": true,
+}
+
+// extractLatex selects the prose and comments of a LaTeX document. Commands,
+// the arguments of commands like \label and \cite, math and verbatim
+// environments are skipped.
+func extractLatex(text string, _ extraction) []region {
+	skipped := []span{}
+	comments := []region{}
+	i := 0
+
+	for i < len(text) {
+		switch {
+		case text[i] == '%':
+			end := lineEnd(text, i)
+			skipped = append(skipped, span{i, end})
+			comments = append(comments, region{Kind: regionComment, Start: i + 1, End: end})
+			i = end
+
+		case strings.HasPrefix(text[i:], "$$"):
+			end := closingIndex(text, i+2, "$$")
+			skipped = append(skipped, span{i, end})
+			i = end
+
+		case text[i] == '$':
+			end := closingIndex(text, i+1, "$")
+			skipped = append(skipped, span{i, end})
+			i = end
+
+		case text[i] == '\\':
+			end := latexCommand(text, i)
+			skipped = append(skipped, span{i, end})
+			i = end
+
+		default:
+			i++
+		}
+	}
+
+	return append(complement(text, skipped), comments...)
+}
+
+// latexCommand returns the offset after the command starting at start,
+// including its arguments which aren't prose and the content of math and
+// verbatim environments it begins.
+func latexCommand(text string, start int) int {
+	i := start + 1
+
+	for i < len(text) && isASCIILetter(text[i]) {
+		i++
+	}
+
+	name := text[start+1 : i]
+
+	if name == "" {
+		switch {
+		case strings.HasPrefix(text[i:], "["):
+			return closingIndex(text, i, `\]`)
+		case strings.HasPrefix(text[i:], "("):
+			return closingIndex(text, i, `\)`)
+		default:
+			// An escaped character like \% or a control symbol like \,
+			return min(i+1, len(text))
+		}
+	}
+
+	if i < len(text) && text[i] == '*' {
+		i++
+	}
+
+	if name == "verb" && i < len(text) {
+		return min(closingIndex(text, i+1, text[i:i+1]), lineEnd(text, i))
+	}
+
+	count, ok := latexArguments[name]
+
+	if !ok {
+		return i
+	}
+
+	environment := ""
+
+	if close := strings.IndexByte(text[i:], '}'); name == "begin" && strings.HasPrefix(text[i:], "{") && close != -1 {
+		environment = text[i+1 : i+close]
+	}
+
+	for arguments := 0; i < len(text) && (text[i] == '[' || text[i] == '{'); {
+		if text[i] == '{' {
+			if arguments == count {
+				break
+			}
+
+			arguments++
+		}
+
+		i += closingBracket(text[i:])
+	}
+
+	if latexRawEnvironments[strings.TrimSuffix(environment, "*")] {
+		end := strings.Index(text[i:], `\end{`+environment+`}`)
+
+		if end == -1 {
+			return len(text)
+		}
+
+		i += end
+	}
+
+	return i
+}
+
+func isASCIILetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,313 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownFence         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+	markdownReferenceLink = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*\S`)
+	markdownTag           = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9-]*(\s[^<>]*)?/?>`)
+	markdownAutolink      = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9+.-]*:[^<>\s]*>|^<[^<>\s@]+@[^<>\s]+>`)
+)
+
+// fenceLanguages maps the names commonly used in the info string of fenced
+// code blocks to language identifiers. Extensions like 'py' are looked up in
+// languageIDs.
+var fenceLanguages = map[string]string{
+	"golang":  "go",
+	"shell":   "sh",
+	"console": "sh",
+	"c++":     "cpp",
+	"c#":      "cs",
+	"jsonc":   "json",
+}
+
+// extractMarkdown selects the prose of a markdown document: paragraphs,
+// headings, link text and alt text of images. Front matter, code, link
+// destinations, html tags and comments are skipped. The code in fenced code
+// blocks is checked like a document in the language of the block if the
+// checkCodeBlocks setting is enabled.
+func extractMarkdown(text string, e extraction) []region {
+	skipped := []span{}
+	embedded := []region{}
+	offset := 0
+
+	if end, ok := frontMatterEnd(text); ok {
+		skipped = append(skipped, span{0, end})
+		offset = end
+	}
+
+	var fence *fencedBlock
+	inComment := false
+
+	for offset < len(text) {
+		end := lineEnd(text, offset)
+		line := text[offset:end]
+		next := min(end+1, len(text))
+
+		switch {
+		case fence != nil:
+			if fence.closedBy(line) {
+				embedded = append(embedded, fence.regions(text[fence.start:offset], e)...)
+				fence = nil
+			}
+
+			skipped = append(skipped, span{offset, next})
+
+		case inComment:
+			close := strings.Index(line, "-->")
+
+			if close == -1 {
+				skipped = append(skipped, span{offset, next})
+				break
+			}
+
+			skipped = append(skipped, span{offset, offset + close + 3})
+			inComment = markdownInline(line[close+3:], offset+close+3, &skipped)
+
+		case markdownFence.MatchString(line):
+			match := markdownFence.FindStringSubmatch(line)
+			fence = &fencedBlock{marker: match[1], info: match[2], start: next}
+			skipped = append(skipped, span{offset, next})
+
+		case markdownReferenceLink.MatchString(line):
+			skipped = append(skipped, span{offset, next})
+
+		default:
+			inComment = markdownInline(line, offset, &skipped)
+		}
+
+		offset = end + 1
+	}
+
+	// An unclosed fence runs until the end of the document
+	if fence != nil && fence.start < len(text) {
+		embedded = append(embedded, fence.regions(text[fence.start:], e)...)
+	}
+
+	return append(complement(text, skipped), embedded...)
+}
+
+// frontMatterEnd returns the offset after the YAML or TOML front matter at
+// the start of a document.
+func frontMatterEnd(text string) (int, bool) {
+	for _, delimiter := range []string{"---", "+++"} {
+		if !strings.HasPrefix(text, delimiter+"\n") && !strings.HasPrefix(text, delimiter+"\r\n") {
+			continue
+		}
+
+		offset := lineEnd(text, 0) + 1
+
+		for offset < len(text) {
+			end := lineEnd(text, offset)
+			line := strings.TrimRight(text[offset:end], "\r")
+
+			if line == delimiter || (delimiter == "---" && line == "...") {
+				return min(end+1, len(text)), true
+			}
+
+			offset = end + 1
+		}
+	}
+
+	return 0, false
+}
+
+// markdownInline adds the parts of a line of prose which aren't checked to
+// skipped. It reports if the line ends inside of an html comment.
+func markdownInline(line string, offset int, skipped *[]span) bool {
+	skip := func(start, end int) {
+		*skipped = append(*skipped, span{offset + start, offset + end})
+	}
+
+	i := 0
+
+	for i < len(line) {
+		rest := line[i:]
+
+		switch {
+		case rest[0] == '\\':
+			i += 2
+
+		case rest[0] == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			close := strings.Index(rest[run:], rest[:run])
+
+			if close == -1 {
+				i += run
+				break
+			}
+
+			end := i + run + close + run
+			skip(i, end)
+			i = end
+
+		case strings.HasPrefix(rest, "<!--"):
+			close := strings.Index(rest[4:], "-->")
+
+			if close == -1 {
+				skip(i, len(line))
+				return true
+			}
+
+			end := i + 4 + close + 3
+			skip(i, end)
+			i = end
+
+		case rest[0] == '<':
+			match := markdownAutolink.FindString(rest)
+
+			if match == "" {
+				match = markdownTag.FindString(rest)
+			}
+
+			if match == "" {
+				i++
+				break
+			}
+
+			skip(i, i+len(match))
+			i += len(match)
+
+		case strings.HasPrefix(rest, "](") || strings.HasPrefix(rest, "]["):
+			// The destination of a link or the label of a reference link
+			close := closingBracket(rest[1:])
+			skip(i, i+1+close)
+			i += 1 + close
+
+		default:
+			i++
+		}
+	}
+
+	return false
+}
+
+type fencedBlock struct {
+	marker string
+	info   string
+
+	// The offset of the first line of code.
+	start int
+}
+
+func (f *fencedBlock) closedBy(line string) bool {
+	trimmed := strings.TrimSpace(line)
+
+	return strings.HasPrefix(trimmed, f.marker) &&
+		strings.Trim(trimmed, f.marker[:1]) == "" &&
+		len(line)-len(strings.TrimLeft(line, " ")) < 4
+}
+
+// regions returns the checked regions of the code in a fenced code block,
+// moved to the offset of the block in the document.
+func (f *fencedBlock) regions(code string, e extraction) []region {
+	languageID, ok := fenceLanguage(f.info)
+
+	if !ok || !e.settings.CheckCodeBlocks {
+		return nil
+	}
+
+	regions, ok := e.checkedRegions(languageID, code)
+
+	if !ok {
+		return nil
+	}
+
+	for i := range regions {
+		// Already filtered by the settings of the language of the block
+		regions[i].Kind = regionCustom
+		regions[i].Start += f.start
+		regions[i].End += f.start
+	}
+
+	return regions
+}
+
+func fenceLanguage(info string) (string, bool) {
+	name := strings.ToLower(strings.Trim(info, "{}."))
+
+	if name == "" {
+		return "", false
+	}
+
+	if languageID, ok := fenceLanguages[name]; ok {
+		return languageID, true
+	}
+
+	if languageID, ok := languageIDs["."+name]; ok {
+		return languageID, true
+	}
+
+	return name, true
+}
+
+// touchesMarkdownDelimiter reports if one of the lines may open or close
+// front matter, a fenced code block or a comment. Changing such a line may
+// change the regions of every following line.
+func touchesMarkdownDelimiter(lines []string) bool {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "---" || trimmed == "+++" || trimmed == "..." {
+			return true
+		}
+
+		for _, delimiter := range []string{"```", "~~~", "<!--", "-->"} {
+			if strings.Contains(line, delimiter) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// markdownBlock returns the first and last line of the block around the
+// lines first through last of a markdown document, which is the fenced code
+// block containing them or the lines between the blank lines around them.
+// The lines must not open or close a fenced code block.
+func markdownBlock(text string, first int, last int) (int, int) {
+	lines := strings.Split(text, "\n")
+	row := 0
+
+	if end, ok := frontMatterEnd(text); ok {
+		row = strings.Count(text[:end], "\n")
+	}
+
+	var fence *fencedBlock
+	fenceStart := 0
+
+	for ; row < len(lines) && row <= last; row++ {
+		switch {
+		case fence != nil:
+			if fence.closedBy(lines[row]) {
+				fence = nil
+			}
+
+		case markdownFence.MatchString(lines[row]):
+			fence = &fencedBlock{marker: markdownFence.FindStringSubmatch(lines[row])[1]}
+			fenceStart = row
+		}
+	}
+
+	if fence != nil {
+		for row < len(lines) && !fence.closedBy(lines[row]) {
+			row++
+		}
+
+		return fenceStart, min(row, len(lines)-1)
+	}
+
+	for first > 0 && strings.TrimSpace(lines[first-1]) != "" {
+		first--
+	}
+
+	for last < len(lines)-1 && strings.TrimSpace(lines[last+1]) != "" {
+		last++
+	}
+
+	return first, last
+}
@@ -0,0 +1,243 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	rstDirective    = regexp.MustCompile(`^\.\.\s+(\|[^|]+\|\s+)?([\w:.-]+)::`)
+	rstTarget       = regexp.MustCompile(`^\.\.\s+_`)
+	rstFootnote     = regexp.MustCompile(`^\.\.\s+\[[^\]]+\]`)
+	rstField        = regexp.MustCompile(`^:[^:\s][^:]*:(\s|$)`)
+	rstRole         = regexp.MustCompile("^:[A-Za-z][\\w.+:-]*:`")
+	rstSubstitution = regexp.MustCompile(`^\|[^|\s][^|]*\|`)
+	rstReference    = regexp.MustCompile(`^\[[#*\w-]*\]_`)
+)
+
+// rstProseDirectives are directives whose arguments are prose.
+var rstProseDirectives = map[string]bool{
+	"admonition": true,
+	"attention":  true,
+	"caution":    true,
+	"danger":     true,
+	"error":      true,
+	"hint":       true,
+	"important":  true,
+	"note":       true,
+	"tip":        true,
+	"warning":    true,
+	"seealso":    true,
+	"topic":      true,
+	"sidebar":    true,
+	"rubric":     true,
+	"table":      true,
+}
+
+// rstRawDirectives are directives whose content isn't prose.
+var rstRawDirectives = map[string]bool{
+	"code":           true,
+	"code-block":     true,
+	"sourcecode":     true,
+	"literalinclude": true,
+	"include":        true,
+	"math":           true,
+	"raw":            true,
+	"highlight":      true,
+	"doctest":        true,
+	"testcode":       true,
+	"testoutput":     true,
+	"graphviz":       true,
+	"productionlist": true,
+}
+
+// rstProseRoles are interpreted text roles whose content is prose. The
+// content of other roles, like code, math or cross references to objects, is
+// skipped.
+var rstProseRoles = map[string]bool{
+	"emphasis":        true,
+	"strong":          true,
+	"title-reference": true,
+	"title":           true,
+	"t":               true,
+	"abbr":            true,
+	"term":            true,
+	"subscript":       true,
+	"sub":             true,
+	"superscript":     true,
+	"sup":             true,
+	"guilabel":        true,
+	"menuselection":   true,
+}
+
+type rstBlockKind int
+
+const (
+	// The indented block after a paragraph ending with '::'.
+	rstLiteral rstBlockKind = iota
+	rstComment
+	rstDirectiveBlock
+	rstRawDirectiveBlock
+	// Python sessions starting with '>>>', which run until a blank line.
+	rstDoctest
+)
+
+// rstBlock is an indented block following a line with the given indentation.
+type rstBlock struct {
+	kind   rstBlockKind
+	indent int
+
+	// Directive options are only allowed before the content.
+	options bool
+}
+
+// extractRst selects the prose and comments of a reStructuredText document.
+// Directives, field names, roles, literals, link targets, substitutions and
+// the content of literal blocks and code directives are skipped.
+func extractRst(text string, _ extraction) []region {
+	skipped := []span{}
+	comments := []region{}
+	var block *rstBlock
+	offset := 0
+
+	for offset < len(text) {
+		end := lineEnd(text, offset)
+		line := strings.TrimRight(text[offset:end], "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		blank := trimmed == ""
+
+		if block != nil && (block.kind == rstDoctest && blank || block.kind != rstDoctest && !blank && indent <= block.indent) {
+			block = nil
+		}
+
+		switch {
+		case block != nil && block.kind == rstComment:
+			skipped = append(skipped, span{offset, end})
+			comments = append(comments, region{Kind: regionComment, Start: offset + indent, End: offset + len(line)})
+
+		case block != nil && block.kind == rstDirectiveBlock && block.options && strings.HasPrefix(trimmed, ":"):
+			skipped = append(skipped, span{offset, end})
+
+		case block != nil && block.kind == rstDirectiveBlock:
+			block.options = block.options && blank
+			rstInline(trimmed, offset+indent, &skipped)
+
+		case block != nil:
+			skipped = append(skipped, span{offset, end})
+
+		case strings.HasPrefix(trimmed, ">>>"):
+			block = &rstBlock{kind: rstDoctest, indent: indent}
+			skipped = append(skipped, span{offset, end})
+
+		case rstDirective.MatchString(trimmed):
+			match := rstDirective.FindStringSubmatchIndex(trimmed)
+			name := trimmed[match[4]:match[5]]
+			block = &rstBlock{kind: rstDirectiveBlock, indent: indent, options: true}
+
+			if rstRawDirectives[name] {
+				block.kind = rstRawDirectiveBlock
+			}
+
+			if !rstProseDirectives[name] {
+				skipped = append(skipped, span{offset, end})
+				break
+			}
+
+			skipped = append(skipped, span{offset, offset + indent + match[1]})
+			rstInline(trimmed[match[1]:], offset+indent+match[1], &skipped)
+
+		case rstTarget.MatchString(trimmed):
+			skipped = append(skipped, span{offset, end})
+
+		case rstFootnote.MatchString(trimmed):
+			label := len(rstFootnote.FindString(trimmed))
+			skipped = append(skipped, span{offset, offset + indent + label})
+			rstInline(trimmed[label:], offset+indent+label, &skipped)
+
+		case trimmed == ".." || strings.HasPrefix(trimmed, ".. "):
+			block = &rstBlock{kind: rstComment, indent: indent}
+			skipped = append(skipped, span{offset, end})
+			comments = append(comments, region{Kind: regionComment, Start: offset + indent + 2, End: offset + len(line)})
+
+		default:
+			start := 0
+
+			if field := rstField.FindString(trimmed); field != "" {
+				start = len(field)
+				skipped = append(skipped, span{offset, offset + indent + start})
+			}
+
+			rstInline(trimmed[start:], offset+indent+start, &skipped)
+
+			if strings.HasSuffix(trimmed, "::") {
+				block = &rstBlock{kind: rstLiteral, indent: indent}
+			}
+		}
+
+		offset = end + 1
+	}
+
+	return append(complement(text, skipped), comments...)
+}
+
+// rstInline adds the parts of a line of prose which aren't checked to
+// skipped.
+func rstInline(line string, offset int, skipped *[]span) {
+	skip := func(start, end int) {
+		*skipped = append(*skipped, span{offset + start, offset + end})
+	}
+
+	i := 0
+
+	for i < len(line) {
+		rest := line[i:]
+
+		switch {
+		case rest[0] == '\\':
+			i += 2
+
+		case strings.HasPrefix(rest, "``"):
+			end := closingIndex(line, i+2, "``")
+			skip(i, end)
+			i = end
+
+		case rest[0] == ':' && rstRole.MatchString(rest):
+			role := rstRole.FindString(rest)
+			name := role[strings.LastIndex(role[:len(role)-2], ":")+1 : len(role)-2]
+			end := i + len(role) - 1
+
+			if !rstProseRoles[name] {
+				end = closingIndex(line, end+1, "`")
+			}
+
+			skip(i, end)
+			i = end
+
+		case rest[0] == '`':
+			end := closingIndex(line, i+1, "`")
+
+			// The target of a hyperlink reference like `text <url>`_
+			if close := strings.LastIndexByte(line[i:end], '>'); close != -1 {
+				if open := strings.LastIndexByte(line[i:i+close], '<'); open != -1 {
+					skip(i+open, i+close+1)
+				}
+			}
+
+			i = end
+
+		case rest[0] == '|' && rstSubstitution.MatchString(rest):
+			end := i + len(rstSubstitution.FindString(rest))
+			skip(i, end)
+			i = end
+
+		case rest[0] == '[' && rstReference.MatchString(rest):
+			end := i + len(rstReference.FindString(rest))
+			skip(i, end)
+			i = end
+
+		default:
+			i++
+		}
+	}
+}
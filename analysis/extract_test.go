@@ -0,0 +1,289 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnlyCommentsAndStringsAreChecked(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		text       string
+		expected   []string
+	}{
+		{"go", "// a cmment\nfunc fooo() string {\n\treturn \"wrold\" + `raww`\n}", []string{"cmment", "wrold", "raww"}},
+		{"python", "def fooo():\n    \"\"\"Docstrng\n    over lnes\"\"\"\n    return 'strng' # commnt", []string{"Docstrng", "lnes", "strng", "commnt"}},
+		{"rust", "fn fooo<'a>(x: &'a str) {} /* blok */ // lne", []string{"blok", "lne"}},
+		{"go", "var x = \"a\\nover\\tover\\x41over\\u00e9over\" + `a\\nover`", []string{}},
+		{"c", "char *x = \"a\\nover\\tover\\101over\";", []string{}},
+		{"rust", "let x = \"a\\nover\\u{1F600}over\";", []string{}},
+		{"python", "x = 'a\\nover\\tover'", []string{}},
+		{"javascript", "const x = `over ${qzx} ${{a: lne}.a} wrold`", []string{"wrold"}},
+		{"python", "x = f\"{zzq} over {x!r:>10} wrold\" + rb'over'", []string{"wrold"}},
+		{"java", "String x = \"a\\nover\" + \"\"\"\n  wrold\"\"\";", []string{"wrold"}},
+		{"json", "{\"x\": \"a\\nover\\u00e9over wrold\"}", []string{"wrold"}},
+		{"yaml", "# cmment\nkeyy: wrold # commnt\nlst:\n  - &anchr itemm\n  - *anchr\n\"quotd\": !!str 'strng'\nflw: {nme: valu, [a]: lne}\nblk: |\n  over blok\n  lnes\nlast: over\n", []string{"cmment", "wrold", "commnt", "itemm", "strng", "valu", "lne", "blok", "lnes"}},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "a", "over", "def", "return", "func", "string", "fn", "str", "x", "var", "let", "char", "const")
+
+		data := createDocumentData(lsp.TextDocumentItem{
+			URI:        "file:///test",
+			LanguageID: c.languageID,
+			Text:       c.text,
+		})
+
+		diagnostics := getDiagnostics(data, state, logger)
+
+		if len(diagnostics) != len(c.expected) {
+			t.Fatalf("%s: expected %d diagnostics, got %v", c.languageID, len(c.expected), diagnostics)
+		}
+
+		for i, word := range c.expected {
+			if diagnostics[i].Message != "Typo in word: "+word {
+				t.Errorf("%s: expected typo %s, got %s", c.languageID, word, diagnostics[i].Message)
+			}
+		}
+	}
+}
+
+func TestCheckedRegionsSetting(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t)
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{
+		CheckedRegions: map[string][]string{"go": {"identifiers"}},
+	}}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{
+		URI:        "file:///test.go",
+		LanguageID: "go",
+		Text:       "var fooo = \"barr\" // bazz",
+	})
+
+	diagnostics := getDiagnostics(data, state, logger)
+
+	if len(diagnostics) != 1 || diagnostics[0].Message != "Typo in word: fooo" {
+		t.Fatalf("Expected only identifiers to be checked, got %v", diagnostics)
+	}
+}
+
+func TestUserQueryOverridesRegions(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		file       string
+		query      string
+		text       string
+		expected   string
+	}{
+		{"go", "main.go", "((comment) @doc (#match? @doc \"^// [A-Z]\"))", "// Documentd\nvar x = \"strng\" // inlne", "Documentd"},
+		{"rust", "lib.rs", "((line_comment (doc_comment) @doc))", "/// Documentd\nlet x = \"strng\"; // inlne", "Documentd"},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t)
+		folder := t.TempDir()
+		state.WorkspaceFolders = []string{folder}
+
+		dir := filepath.Join(folder, ".proof", "queries", c.languageID)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, queryFileName), []byte(c.query), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		data := createDocumentData(lsp.TextDocumentItem{
+			URI:        pathToURI(filepath.Join(folder, c.file)),
+			LanguageID: c.languageID,
+			Text:       c.text,
+		})
+
+		diagnostics := getDiagnostics(data, state, logger)
+
+		if len(diagnostics) != 1 || diagnostics[0].Message != "Typo in word: "+c.expected {
+			t.Fatalf("%s: expected only the doc comment to be checked, got %v", c.languageID, diagnostics)
+		}
+	}
+}
+
+func TestUserQueryChangesWhileExtracting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, queryFileName)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := range 50 {
+			query := "(comment) @comment"
+
+			if i%2 == 1 {
+				query = "(comment) @doc"
+			}
+
+			// The file is replaced at once, so it is never read half written.
+			// Query files are compiled again when their modification time
+			// changes.
+			next := path + ".next"
+			modified := time.Now().Add(time.Duration(i) * time.Second)
+
+			if err := os.WriteFile(next, []byte(query), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := os.Chtimes(next, modified, modified); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := os.Rename(next, path); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// Queries are used by several extractions at once while they are
+	// compiled again
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				regions, err := extractTreeSitter("go", path, "// comment\nvar x = 1")
+
+				if err == nil && len(regions) != 1 {
+					t.Errorf("Expected the comment to be selected, got %v", regions)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestMarkdownChecksOnlyProse(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "title", "see", "the", "and", "an", "a", "comment")
+
+	text := "---\nauthr: someone\n---\n# Titel\n\nSee `inlin` and [the lnk](https://exampl.com/pth \"titl\") " +
+		"<span clas=\"x\">an</span> ![imag](pic.png) <!-- commnt -->\n\n```go\n// a cde comment\nvar x = 1\n```\n[rf]: https://exampl.org"
+
+	data := createDocumentData(lsp.TextDocumentItem{
+		URI:        "file:///test.md",
+		LanguageID: "markdown",
+		Text:       text,
+	})
+
+	expected := []string{"Titel", "lnk", "imag"}
+	diagnostics := getDiagnostics(data, state, logger)
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+
+	for i, word := range expected {
+		if diagnostics[i].Message != "Typo in word: "+word {
+			t.Errorf("Expected typo %s, got %s", word, diagnostics[i].Message)
+		}
+	}
+
+	enabled := true
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{CheckCodeBlocks: &enabled}}, logger)
+
+	diagnostics = getDiagnostics(data, state, logger)
+
+	if len(diagnostics) != len(expected)+1 || diagnostics[len(expected)].Message != "Typo in word: cde" {
+		t.Fatalf("Expected the comment in the code block to be checked, got %v", diagnostics)
+	}
+}
+
+func TestMarkupChecksOnlyProse(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		languageID string
+		text       string
+		expected   []string
+	}{
+		{
+			"latex",
+			"\\documentclass{artcle}\n\\usepackage[utf8]{inputenc}\n\\begin{document}\n\\section{Titel}\n" +
+				"See the \\textbf{txt} \\cite{knuthh} and $x = \\alpha + betta$ \\label{sec:intro}.\n% a commnt\n" +
+				"\\begin{equation}\n  f(x) = \\frac{a}{bb}\n\\end{equation}\n\\verb|cde| \\href{https://exampl.com}{lnk}\n\\end{document}",
+			[]string{"Titel", "txt", "commnt", "lnk"},
+		},
+		{
+			"html",
+			"<!DOCTYPE html>\n<html lang=\"en\">\n<body class=\"contnr\">\n<!-- a commnt -->\n<img src=\"pic.png\" alt=\"imag\">\n" +
+				"<p title=\"titl\">See the txt</p>\n<script>var fooo = 1</script>\n</body>\n</html>",
+			[]string{"commnt", "imag", "titl", "txt"},
+		},
+		{
+			"rst",
+			"Titel\n=====\n\n.. code-block:: pythn\n\n   def fooo(): pass\n\n" +
+				"See the ``inlin`` and :func:`barr` and `lnk <https://exampl.com>`_ |subst|.\n\n" +
+				".. note:: A nte\n\n.. _targt: https://exampl.org\n.. a commnt\n\n:fieldd: txt\n\nSee::\n\n    literl block",
+			[]string{"Titel", "lnk", "nte", "commnt", "txt"},
+		},
+		{
+			"asciidoc",
+			"= Titel\n:authr: someone\n\n[source,go]\n----\nfooo := 1\n----\n\n" +
+				"See the `inlin` and link:https://exampl.com[lnk] and <<secid,txt>> {attrr}.\n// a commnt\n" +
+				"image::pic.png[imag]\n////\nblck\n////",
+			[]string{"Titel", "lnk", "txt", "commnt", "imag", "blck"},
+		},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "see", "the", "and", "a", "an")
+		data := createDocumentData(lsp.TextDocumentItem{
+			URI:        "file:///test",
+			LanguageID: c.languageID,
+			Text:       c.text,
+		})
+
+		diagnostics := getDiagnostics(data, state, logger)
+
+		if len(diagnostics) != len(c.expected) {
+			t.Errorf("%s: Expected %d diagnostics, got %v", c.languageID, len(c.expected), diagnostics)
+			continue
+		}
+
+		lines := strings.Split(c.text, "\n")
+
+		for i, word := range c.expected {
+			r := diagnostics[i].Range
+			found := lines[r.Start.Line][r.Start.Character:r.End.Character]
+
+			if diagnostics[i].Message != "Typo in word: "+word || found != word {
+				t.Errorf("%s: Expected typo %s, got %s at %q", c.languageID, word, diagnostics[i].Message, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package analysis
+
+import "strings"
+
+// yamlIndicators are the characters which separate the items of flow
+// collections.
+const yamlIndicators = ",[]{}"
+
+// extractYaml finds the comments and scalar values of a YAML document.
+// Keys, anchors, aliases, tags and directives are skipped.
+func extractYaml(text string, _ extraction) []region {
+	regions := []region{}
+	offset := 0
+
+	// The depth of the flow collections around the offset
+	flow := 0
+
+	// The indentation of the line starting a block scalar while in one
+	blockIndent := -1
+
+	for offset < len(text) {
+		end := lineEnd(text, offset)
+		line := text[offset:end]
+		content := offset + len(line) - len(strings.TrimLeft(line, " "))
+		indent := content - offset
+
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				regions = append(regions, region{Kind: regionString, Start: content, End: end})
+				offset = end + 1
+				continue
+			}
+
+			blockIndent = -1
+		}
+
+		if indent == 0 && (strings.HasPrefix(line, "%") || yamlDocumentMarker(line)) {
+			offset = end + 1
+			continue
+		}
+
+		var header bool
+		regions, offset, flow, header = yamlLine(text, content, flow, regions)
+
+		if header {
+			blockIndent = indent
+		}
+	}
+
+	return regions
+}
+
+// yamlLine appends the regions of the line starting at offset. Quoted
+// scalars may continue on the following lines, so it returns the offset of
+// the next line it hasn't read yet. It reports if the line starts a block
+// scalar.
+func yamlLine(text string, offset int, flow int, regions []region) ([]region, int, int, bool) {
+	header := false
+
+	for offset < len(text) {
+		c := text[offset]
+
+		switch {
+		case c == '\n':
+			return regions, offset + 1, flow, header
+		case c == ' ' || c == '\t' || c == '\r':
+			offset++
+		case c == '#' && (offset == 0 || isYamlSpace(text[offset-1]) || text[offset-1] == '\n'):
+			end := lineEnd(text, offset)
+			regions = append(regions, region{Kind: regionComment, Start: offset, End: end})
+			offset = end
+		case strings.IndexByte("-?:", c) >= 0 && yamlSeparated(text, offset+1, flow):
+			offset++
+		case strings.IndexByte(yamlIndicators, c) >= 0:
+			switch c {
+			case '[', '{':
+				flow++
+			case ']', '}':
+				flow = max(flow-1, 0)
+			}
+
+			offset++
+		case c == '&' || c == '*' || c == '!':
+			offset = yamlPropertyEnd(text, offset, flow)
+		case (c == '|' || c == '>') && flow == 0:
+			header = true
+			offset++
+
+			for offset < len(text) && strings.IndexByte("+-0123456789", text[offset]) >= 0 {
+				offset++
+			}
+		case c == '"' || c == '\'':
+			end := yamlQuotedEnd(text, offset)
+
+			if !yamlKey(text, end, flow) {
+				regions = append(regions, region{Kind: regionString, Start: offset + 1, End: max(end-1, offset+1)})
+			}
+
+			offset = end
+		default:
+			end := yamlPlainEnd(text, offset, flow)
+
+			if !yamlKey(text, end, flow) {
+				regions = append(regions, region{Kind: regionString, Start: offset, End: end})
+			}
+
+			offset = max(end, offset+1)
+		}
+	}
+
+	return regions, offset, flow, header
+}
+
+// yamlDocumentMarker reports if the line starts or ends a document.
+func yamlDocumentMarker(line string) bool {
+	for _, marker := range []string{"---", "..."} {
+		if strings.HasPrefix(line, marker) && yamlSeparated(line, len(marker), 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// yamlSeparated reports if the character before offset is followed by a
+// space or the end of the line, or an indicator inside flow collections.
+func yamlSeparated(text string, offset int, flow int) bool {
+	if offset >= len(text) || isYamlSpace(text[offset]) || text[offset] == '\n' {
+		return true
+	}
+
+	return flow > 0 && strings.IndexByte(yamlIndicators, text[offset]) >= 0
+}
+
+// yamlKey reports if the scalar ending at offset is followed by the colon
+// of a mapping key.
+func yamlKey(text string, offset int, flow int) bool {
+	for offset < len(text) && isYamlSpace(text[offset]) {
+		offset++
+	}
+
+	return offset < len(text) && text[offset] == ':' && (flow > 0 || yamlSeparated(text, offset+1, flow))
+}
+
+// yamlPropertyEnd returns the offset after the anchor, alias or tag starting
+// at offset.
+func yamlPropertyEnd(text string, offset int, flow int) int {
+	for offset < len(text) && !isYamlSpace(text[offset]) && text[offset] != '\n' {
+		if flow > 0 && strings.IndexByte(yamlIndicators, text[offset]) >= 0 {
+			break
+		}
+
+		offset++
+	}
+
+	return offset
+}
+
+// yamlQuotedEnd returns the offset after the quote closing the scalar
+// starting at offset, or the length of the text if it isn't closed.
+// Double quoted scalars escape with a backslash, single quoted ones by
+// doubling the quote.
+func yamlQuotedEnd(text string, offset int) int {
+	quote := text[offset]
+
+	for i := offset + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+
+	return len(text)
+}
+
+// yamlPlainEnd returns the offset after the unquoted scalar starting at
+// offset, without trailing spaces. It ends at the end of the line, a
+// comment, the colon of a key or an indicator inside flow collections.
+func yamlPlainEnd(text string, offset int, flow int) int {
+	end := offset
+
+	for i := offset; i < len(text); i++ {
+		c := text[i]
+
+		if c == '\n' || c == '#' && i > offset && isYamlSpace(text[i-1]) {
+			break
+		}
+
+		if c == ':' && yamlSeparated(text, i+1, flow) {
+			break
+		}
+
+		if flow > 0 && strings.IndexByte(yamlIndicators, c) >= 0 {
+			break
+		}
+
+		if !isYamlSpace(c) && c != '\r' {
+			end = i + 1
+		}
+	}
+
+	return end
+}
+
+func isYamlSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
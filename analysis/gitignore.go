@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ignoreRule struct {
+	// Slash separated directory of the .gitignore file relative to the root
+	// of the walk. Empty for the root itself.
+	base    string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore holds the rules of all .gitignore files loaded while walking a
+// directory tree. Rules are evaluated in order and the last match wins.
+type gitignore struct {
+	rules []ignoreRule
+}
+
+// load reads the .gitignore file in dir, if there is one. rel is the slash
+// separated path of dir relative to the root of the walk.
+func (g *gitignore) load(dir string, rel string) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+
+	if err != nil {
+		return
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(rel, scanner.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+}
+
+// loadParents reads the .gitignore files of top and the directories below it
+// which contain path, excluding path itself. Paths outside of top have no
+// parents to load.
+func (g *gitignore) loadParents(top string, path string) {
+	rel, err := filepath.Rel(top, filepath.Dir(path))
+
+	if err != nil || !filepath.IsLocal(rel) || filepath.Clean(path) == top {
+		return
+	}
+
+	dir := top
+	base := ""
+	g.load(dir, base)
+
+	if rel == "." {
+		return
+	}
+
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		dir = filepath.Join(dir, name)
+		base = joinRel(base, name)
+		g.load(dir, base)
+	}
+}
+
+// ignored reports if the slash separated path rel, relative to the root of
+// the walk, is ignored.
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+
+			target = rel[len(rule.base)+1:]
+		}
+
+		if rule.pattern.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+func parseIgnoreRule(base string, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A pattern containing a slash anywhere but at the end only matches
+	// relative to the directory of the .gitignore file.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	expression := globToRegexp(line)
+
+	if anchored {
+		expression = "^" + expression + "$"
+	} else {
+		expression = "(^|/)" + expression + "$"
+	}
+
+	pattern, err := regexp.Compile(expression)
+
+	if err != nil {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = pattern
+
+	return rule, true
+}
+
+func globToRegexp(glob string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+
+		case strings.HasPrefix(glob[i:], "**/"):
+			builder.WriteString("(.*/)?")
+			i += 2
+
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			builder.WriteString("/.*")
+			i += 2
+
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+
+		case c == '*':
+			builder.WriteString("[^/]*")
+
+		case c == '?':
+			builder.WriteString("[^/]")
+
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+
+			if end == -1 {
+				builder.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			builder.WriteString("[" + class + "]")
+			i += end + 1
+
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return builder.String()
+}
+
+// joinRel joins a slash separated relative path with a name.
+func joinRel(rel string, name string) string {
+	if rel == "" {
+		return name
+	}
+
+	return path.Join(rel, name)
+}
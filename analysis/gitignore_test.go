@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	ignore := gitignore{}
+
+	for _, line := range []string{"# comment", "build/", "*.log", "!keep.log", "/root.txt", "docs/**/*.tmp"} {
+		if rule, ok := parseIgnoreRule("", line); ok {
+			ignore.rules = append(ignore.rules, rule)
+		}
+	}
+
+	if rule, ok := parseIgnoreRule("sub", "local.txt"); ok {
+		ignore.rules = append(ignore.rules, rule)
+	}
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"build", true, true},
+		{"build", false, false},
+		{"a/build", true, true},
+		{"x.log", false, true},
+		{"a/b/x.log", false, true},
+		{"keep.log", false, false},
+		{"root.txt", false, true},
+		{"a/root.txt", false, false},
+		{"docs/a/b/c.tmp", false, true},
+		{"docs/c.tmp", false, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"main.go", false, false},
+	}
+
+	for _, c := range cases {
+		if actual := ignore.ignored(c.path, c.isDir); actual != c.expected {
+			t.Errorf("ignored(%q, %v): expected %v, got %v", c.path, c.isDir, c.expected, actual)
+		}
+	}
+}
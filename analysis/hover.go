@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"fmt"
+	"log"
+	"proof/lsp"
+	"strings"
+)
+
+func (s *State) Hover(request lsp.HoverTextRequest, uri string, logger *log.Logger) lsp.HoverResponse {
+	position := request.Params.Position
+	document, ok := s.document(uri)
+
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
+	if !ok {
+		return lsp.NewHoverResponse(request.ID, nil)
+	}
+
+	line, ok := lineAt(document.Text, position.Line)
+
+	if !ok || document.isExcluded(s, logger) {
+		return lsp.NewHoverResponse(request.ID, nil)
+	}
+
+	// Only the words which are checked are described
+	settings, directives := s.documentSettings(document, logger)
+	checked, ok := s.checkedLine(document, settings, directives, position.Line, logger)
+
+	if !ok {
+		return lsp.NewHoverResponse(request.ID, nil)
+	}
+
+	word, ok := wordAt(position.Line, checked, runeColumn(line, position.Character, s.PositionEncoding))
+
+	if !ok {
+		return lsp.NewHoverResponse(request.ID, nil)
+	}
+
+	logger.Printf("Hovering word: %s", word.Text)
+
+	rng := wordRange(line, word, s.PositionEncoding)
+
+	return lsp.NewHoverResponse(request.ID, &lsp.HoverResult{
+		Contents: lsp.MarkupContent{
+			Kind:  lsp.Markdown,
+			Value: s.describeWord(word.Text, settings),
+		},
+		Range: &rng,
+	})
+}
+
+func (s *State) describeWord(word string, settings Settings) string {
+	var builder strings.Builder
+
+	match, ok := s.matchWord(word, settings)
+
+	if ok {
+		fmt.Fprintf(&builder, "**proof**: `%s` is a known word\n\n", word)
+
+		if match.Plural {
+			fmt.Fprintf(&builder, "Accepted as an implicit plural of `%s` from the %s.", match.Word, match.Source)
+		} else {
+			fmt.Fprintf(&builder, "Accepted by the %s.", match.Source)
+		}
+
+		return builder.String()
+	}
+
+	if s.isForbidden(word, settings) {
+		fmt.Fprintf(&builder, "**proof**: `%s` is rejected by the %s\n\n", word, sourceForbiddenWords)
+	} else {
+		fmt.Fprintf(&builder, "**proof**: `%s` is not a known word\n\n", word)
+	}
+
+	suggestions := s.suggest(strings.ToLower(word), settings)
+
+	if len(suggestions) == 0 {
+		builder.WriteString("No suggestions found.")
+		return builder.String()
+	}
+
+	builder.WriteString("Suggestions:\n")
+
+	for i, suggestion := range suggestions {
+		fmt.Fprintf(&builder, "\n%d. `%s`", i+1, suggestion)
+	}
+
+	return builder.String()
+}
+
+// wordAt finds the word in the line which contains the given character.
+func wordAt(row int, line string, character int) (Word, bool) {
+	for _, word := range splitIntoWords(row, 0, line) {
+		if character >= word.Start && character < word.End {
+			return word, true
+		}
+	}
+
+	return Word{}, false
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"strings"
+	"testing"
+)
+
+func TestHover(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	cases := []struct {
+		name       string
+		languageID string
+		text       string
+		word       string
+		expected   string
+	}{
+		{"unknown word", "text", "a wrold", "wrold", "`wrold` is not a known word"},
+		{"known word", "text", "a world", "world", "`world` is a known word"},
+		{"code", "go", "func fooo() {} // wrold", "fooo", ""},
+		{"comment", "go", "func fooo() {} // wrold", "wrold", "`wrold` is not a known word"},
+		{"url", "text", "a https://wrold.example", "wrold", ""},
+		{"directive", "text", "proof:ignore-next-line\na wrold", "wrold", ""},
+		{"disabled file", "text", "proof:disable-file\na wrold", "wrold", ""},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "a", "world", "func", "proof", "ignore", "next", "line", "disable", "file")
+		uri := "file:///test"
+		state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: c.languageID, Text: c.text}, logger)
+
+		row := strings.Count(c.text[:strings.Index(c.text, c.word)], "\n")
+		line := strings.Split(c.text, "\n")[row]
+		position := lsp.Position{Line: row, Character: strings.Index(line, c.word) + 1}
+
+		response := state.Hover(lsp.HoverTextRequest{
+			Params: lsp.HoverParams{
+				TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+					TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+					Position:     position,
+				},
+			},
+		}, uri, logger)
+
+		if c.expected == "" {
+			if response.Result != nil {
+				t.Errorf("%s: Expected no hover, got %q", c.name, response.Result.Contents.Value)
+			}
+
+			continue
+		}
+
+		if response.Result == nil || !strings.Contains(response.Result.Contents.Value, c.expected) {
+			t.Errorf("%s: Expected a hover containing %q, got %v", c.name, c.expected, response.Result)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languageIDs maps file extensions to the language identifiers LSP clients
+// commonly send. It is used for files which are not opened by the client.
+var languageIDs = map[string]string{
+	".adoc":     "asciidoc",
+	".asciidoc": "asciidoc",
+	".c":        "c",
+	".h":        "c",
+	".cc":       "cpp",
+	".cpp":      "cpp",
+	".hpp":      "cpp",
+	".cs":       "cs",
+	".css":      "css",
+	".go":       "go",
+	".html":     "html",
+	".java":     "java",
+	".js":       "javascript",
+	".jsx":      "javascriptreact",
+	".json":     "json",
+	".kt":       "kotlin",
+	".lua":      "lua",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".nix":      "nix",
+	".php":      "php",
+	".py":       "python",
+	".rb":       "ruby",
+	".rst":      "restructuredtext",
+	".rs":       "rust",
+	".sh":       "sh",
+	".bash":     "sh",
+	".zsh":      "zsh",
+	".sql":      "sql",
+	".swift":    "swift",
+	".tex":      "latex",
+	".toml":     "toml",
+	".ts":       "typescript",
+	".tsx":      "typescriptreact",
+	".txt":      "text",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".zig":      "zig",
+}
+
+func languageIDFromPath(path string) string {
+	extension := strings.ToLower(filepath.Ext(path))
+
+	if languageID, ok := languageIDs[extension]; ok {
+		return languageID
+	}
+
+	return strings.TrimPrefix(extension, ".")
+}
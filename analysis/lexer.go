@@ -0,0 +1,250 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lexer finds comments, string literals and identifiers in languages with a
+// syntax similar enough to be described by their delimiters.
+type lexer struct {
+	lineComments  []string
+	blockComments []delimiters
+	strings       []delimiters
+}
+
+type delimiters struct {
+	Open  string
+	Close string
+
+	// Set for strings in which a backslash escapes the next character.
+	Escapes bool
+
+	// Set for strings which may span multiple lines. Other strings end at
+	// the end of the line if they aren't closed.
+	Multiline bool
+
+	// Set for strings in which ${...} embeds code, which isn't checked.
+	Interpolates bool
+}
+
+var (
+	cComments      = []string{"//"}
+	cBlockComments = []delimiters{{Open: "/*", Close: "*/"}}
+	hashComments   = []string{"#"}
+
+	doubleQuoted = delimiters{Open: `"`, Close: `"`, Escapes: true}
+	singleQuoted = delimiters{Open: "'", Close: "'", Escapes: true}
+	backticks    = delimiters{Open: "`", Close: "`", Escapes: true, Multiline: true, Interpolates: true}
+	tripleDouble = delimiters{Open: `"""`, Close: `"""`, Escapes: true, Multiline: true}
+	tripleSingle = delimiters{Open: "'''", Close: "'''", Escapes: true, Multiline: true}
+
+	cLike = lexer{
+		lineComments:  cComments,
+		blockComments: cBlockComments,
+		strings:       []delimiters{doubleQuoted, singleQuoted},
+	}
+
+	javaScript = lexer{
+		lineComments:  cComments,
+		blockComments: cBlockComments,
+		strings:       []delimiters{doubleQuoted, singleQuoted, backticks},
+	}
+
+	hashLike = lexer{
+		lineComments: hashComments,
+		strings:      []delimiters{doubleQuoted, singleQuoted},
+	}
+)
+
+// lexers maps language identifiers to the lexer used to extract their
+// regions.
+var lexers = map[string]lexer{
+	"c":               cLike,
+	"cpp":             cLike,
+	"cs":              cLike,
+	"csharp":          cLike,
+	"dart":            cLike,
+	"kotlin":          cLike,
+	"php":             cLike,
+	"scala":           cLike,
+	"swift":           cLike,
+	"javascript":      javaScript,
+	"javascriptreact": javaScript,
+	"typescript":      javaScript,
+	"typescriptreact": javaScript,
+	"rust": {
+		// Single quotes are left out since they also start lifetimes
+		lineComments:  cComments,
+		blockComments: cBlockComments,
+		strings:       []delimiters{doubleQuoted},
+	},
+	"zig": {
+		lineComments: cComments,
+		strings:      []delimiters{doubleQuoted, singleQuoted},
+	},
+	"ruby":   hashLike,
+	"sh":     hashLike,
+	"bash":   hashLike,
+	"zsh":    hashLike,
+	"fish":   hashLike,
+	"perl":   hashLike,
+	"r":      hashLike,
+	"elixir": {lineComments: hashComments, strings: []delimiters{tripleDouble, doubleQuoted, singleQuoted}},
+	"toml":   {lineComments: hashComments, strings: []delimiters{tripleDouble, tripleSingle, doubleQuoted, {Open: "'", Close: "'"}}},
+	"nix": {
+		lineComments:  hashComments,
+		blockComments: cBlockComments,
+		strings:       []delimiters{doubleQuoted, {Open: "''", Close: "''", Multiline: true}},
+	},
+	"lua": {
+		lineComments:  []string{"--"},
+		blockComments: []delimiters{{Open: "--[[", Close: "]]"}},
+		strings:       []delimiters{{Open: "[[", Close: "]]", Multiline: true}, doubleQuoted, singleQuoted},
+	},
+	"sql": {
+		lineComments:  []string{"--"},
+		blockComments: cBlockComments,
+		strings:       []delimiters{{Open: "'", Close: "'"}, doubleQuoted},
+	},
+	"haskell": {
+		lineComments:  []string{"--"},
+		blockComments: []delimiters{{Open: "{-", Close: "-}"}},
+		strings:       []delimiters{doubleQuoted},
+	},
+}
+
+func (l lexer) extract(text string, _ extraction) []region {
+	regions := []region{}
+	offset := 0
+
+	for offset < len(text) {
+		rest := text[offset:]
+
+		if end, ok := l.blockComment(text, offset); ok {
+			regions = append(regions, region{Kind: regionComment, Start: offset, End: end})
+			offset = end
+			continue
+		}
+
+		if l.lineComment(rest) {
+			end := lineEnd(text, offset)
+			regions = append(regions, region{Kind: regionComment, Start: offset, End: end})
+			offset = end
+			continue
+		}
+
+		if literal, end, ok := l.stringLiteral(text, offset); ok {
+			regions = append(regions, literal...)
+			offset = end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+
+		if unicode.IsLetter(r) || r == '_' {
+			end := identifierEnd(text, offset)
+			regions = append(regions, region{Kind: regionIdentifier, Start: offset, End: end})
+			offset = end
+			continue
+		}
+
+		offset += size
+	}
+
+	return regions
+}
+
+func (l lexer) lineComment(rest string) bool {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l lexer) blockComment(text string, offset int) (int, bool) {
+	for _, comment := range l.blockComments {
+		if strings.HasPrefix(text[offset:], comment.Open) {
+			return closingIndex(text, offset+len(comment.Open), comment.Close), true
+		}
+	}
+
+	return 0, false
+}
+
+// stringLiteral returns the regions of the string literal starting at
+// offset, without the code embedded in it, and the offset after it.
+func (l lexer) stringLiteral(text string, offset int) ([]region, int, bool) {
+	for _, literal := range l.strings {
+		if !strings.HasPrefix(text[offset:], literal.Open) {
+			continue
+		}
+
+		regions := []region{}
+		start := offset
+		i := offset + len(literal.Open)
+
+		for i < len(text) {
+			switch {
+			case literal.Escapes && text[i] == '\\':
+				i += 2
+			case literal.Interpolates && strings.HasPrefix(text[i:], "${"):
+				regions = append(regions, region{Kind: regionString, Start: start, End: i})
+				i = interpolationEnd(text, i+2)
+				start = i
+			case strings.HasPrefix(text[i:], literal.Close):
+				end := i + len(literal.Close)
+				return append(regions, region{Kind: regionString, Start: start, End: end}), end, true
+			case text[i] == '\n' && !literal.Multiline:
+				return append(regions, region{Kind: regionString, Start: start, End: i}), i, true
+			default:
+				i++
+			}
+		}
+
+		end := min(i, len(text))
+
+		return append(regions, region{Kind: regionString, Start: start, End: end}), end, true
+	}
+
+	return nil, 0, false
+}
+
+// interpolationEnd returns the offset after the brace closing the code
+// embedded in a string, which starts at offset. Nested braces are skipped.
+func interpolationEnd(text string, offset int) int {
+	depth := 1
+
+	for i := offset; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(text)
+}
+
+func identifierEnd(text string, offset int) int {
+	for offset < len(text) {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+
+		offset += size
+	}
+
+	return offset
+}
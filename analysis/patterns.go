@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"fmt"
+	"proof/lsp"
+	"regexp"
+)
+
+// compilePatterns compiles the regular expressions of the settings, so that
+// invalid patterns are reported by SettingsErrors right away.
+func (s *State) compilePatterns(proof lsp.ProofSettings) {
+	s.compiled("ignoredPatterns", proof.IgnoredPatterns)
+	s.compiled("ignoredWordPatterns", proof.IgnoredWordPatterns)
+	s.compiled("excludedFilePatterns", proof.ExcludedFilePatterns)
+}
+
+// withPatterns adds the compiled regular expressions of the patterns in the
+// settings. Invalid patterns are left out.
+func (s *State) withPatterns(settings Settings) Settings {
+	settings.ignoredPatterns = s.compiled("ignoredPatterns", settings.IgnoredPatterns)
+	settings.ignoredWordPatterns = s.compiled("ignoredWordPatterns", settings.IgnoredWordPatterns)
+	settings.excludedFilePatterns = s.compiled("excludedFilePatterns", settings.ExcludedFilePatterns)
+
+	return settings
+}
+
+// compiled returns the compiled patterns of a setting. Every pattern is only
+// compiled once. Patterns failing to compile are remembered as well, so
+// every invalid pattern is only reported once.
+func (s *State) compiled(setting string, patterns []string) []*regexp.Regexp {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	compiled := []*regexp.Regexp{}
+
+	for _, pattern := range patterns {
+		re, ok := s.patterns[pattern]
+
+		if !ok {
+			var err error
+			re, err = regexp.Compile(pattern)
+
+			if err != nil {
+				s.settingsErrors = append(s.settingsErrors, fmt.Errorf("invalid pattern in %s: %w", setting, err))
+			}
+
+			s.patterns[pattern] = re
+		}
+
+		if re != nil {
+			compiled = append(compiled, re)
+		}
+	}
+
+	return compiled
+}
+
+// SettingsErrors returns the errors found in the settings, like patterns
+// which failed to compile or config files which failed to parse, since it
+// was last called.
+func (s *State) SettingsErrors() []error {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	errors := s.settingsErrors
+	s.settingsErrors = nil
+
+	return errors
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"testing"
+)
+
+func TestIgnoredPatterns(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "see", "for", "the", "query")
+
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{
+		IgnoredPatterns:     []string{`[A-Z]{2,}-\d+`, `SELECT .* FROM \w+`},
+		IgnoredWordPatterns: []string{`^[A-Z]{3,}$`, `^acme`},
+	}}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{
+		URI:        "file:///test.txt",
+		LanguageID: "text",
+		Text:       "see ABCD-1234 for the query SELECT colmn FROM tbl\nXYZQW acmeconfig and typpo",
+	})
+
+	diagnostics := getDiagnostics(data, state, logger)
+	expected := []string{"and", "typpo"}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+
+	for i, word := range expected {
+		if diagnostics[i].Message != "Typo in word: "+word {
+			t.Errorf("Expected typo %s, got %s", word, diagnostics[i].Message)
+		}
+	}
+}
+
+func TestInvalidPatternsAreReportedOnce(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello")
+
+	settings := lsp.Settings{Proof: lsp.ProofSettings{
+		IgnoredPatterns:      []string{`(unclosed`, `hel+o`},
+		IgnoredWordPatterns:  []string{`[z-a]`},
+		ExcludedFilePatterns: []string{`*.txt`},
+	}}
+
+	state.UpdateSettings(settings, logger)
+
+	if errors := state.SettingsErrors(); len(errors) != 3 {
+		t.Fatalf("Expected 3 invalid patterns, got %v", errors)
+	}
+
+	state.UpdateSettings(settings, logger)
+
+	if errors := state.SettingsErrors(); len(errors) != 0 {
+		t.Errorf("Expected the invalid patterns to be reported once, got %v", errors)
+	}
+
+	data := createDocumentData(lsp.TextDocumentItem{URI: "file:///test.txt", LanguageID: "text", Text: "hello wrld"})
+
+	if data.isExcluded(state, logger) {
+		t.Errorf("Expected invalid exclusion patterns to be skipped")
+	}
+
+	if diagnostics := getDiagnostics(data, state, logger); len(diagnostics) != 1 {
+		t.Errorf("Expected the valid patterns to be used, got %v", diagnostics)
+	}
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// FilePoller detects changes to the dictionary and config files for clients
+// which can't watch files for proof. Changes are detected by the modification
+// time and size of the files.
+type FilePoller struct {
+	state  *State
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func NewFilePoller(state *State) *FilePoller {
+	return &FilePoller{
+		state:  state,
+		stamps: make(map[string]fileStamp),
+	}
+}
+
+// Poll reloads the dictionaries and config files which changed since the
+// last poll, including the ones which were created or deleted. Files are not
+// reloaded the first time they are seen. It reports if any file was
+// reloaded.
+func (p *FilePoller) Poll(logger *log.Logger) bool {
+	reloaded := false
+
+	for _, path := range p.state.DictionaryPaths() {
+		if p.changed(path) && p.state.reloadDictionaryFile(path, logger) {
+			reloaded = true
+		}
+	}
+
+	for _, path := range p.state.ConfigFilePaths() {
+		if p.changed(path) && p.state.ReloadConfigFile(pathToURI(path), logger) {
+			reloaded = true
+		}
+	}
+
+	return reloaded
+}
+
+// changed reports if a file changed since the last poll. Files seen for the
+// first time haven't changed.
+func (p *FilePoller) changed(path string) bool {
+	stamp := fileStamp{}
+
+	if info, err := os.Stat(path); err == nil {
+		stamp = fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+	}
+
+	previous, seen := p.stamps[path]
+	p.stamps[path] = stamp
+
+	return seen && previous != stamp
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"testing"
+)
+
+func TestDictionaryChangesAreReloaded(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+	project := filepath.Join(root, DefaultProjectDictionary)
+
+	state := newTestState(t, "a")
+	state.Initialize(lsp.InitializeRequestParams{
+		WorkspaceFolders: []lsp.WorkspaceFolder{{URI: pathToURI(root)}},
+	}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{URI: pathToURI(filepath.Join(root, "a.txt")), LanguageID: "text", Text: "jargn"})
+	poller := NewFilePoller(state)
+
+	if poller.Poll(logger) {
+		t.Error("Expected dictionaries not to be reloaded when they are first seen")
+	}
+
+	// Another instance creates the project dictionary
+	if err := os.MkdirAll(filepath.Dir(project), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(project, []byte("jargn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !poller.Poll(logger) {
+		t.Fatal("Expected the created project dictionary to be reloaded")
+	}
+
+	if diagnostics := getDiagnostics(data, state, logger); len(diagnostics) != 0 {
+		t.Errorf("Expected the reloaded word to be known, got %v", diagnostics)
+	}
+
+	if poller.Poll(logger) {
+		t.Error("Expected unchanged dictionaries not to be reloaded")
+	}
+
+	// The word is removed by hand and the client reports the change
+	if err := os.WriteFile(project, []byte("other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.ReloadDictionary(pathToURI(project), logger) {
+		t.Fatal("Expected the project dictionary to be reloaded")
+	}
+
+	if diagnostics := getDiagnostics(data, state, logger); len(diagnostics) != 1 {
+		t.Errorf("Expected the removed word to be unknown, got %v", diagnostics)
+	}
+
+	if state.ReloadDictionary(data.URI, logger) {
+		t.Error("Expected other files not to be reloaded")
+	}
+}
+
+func TestConfigFileChangesArePolled(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+
+	state := newTestState(t, "a")
+	state.Initialize(lsp.InitializeRequestParams{
+		WorkspaceFolders: []lsp.WorkspaceFolder{{URI: pathToURI(root)}},
+	}, logger)
+
+	data := createDocumentData(lsp.TextDocumentItem{URI: pathToURI(filepath.Join(root, "a.txt")), LanguageID: "text", Text: "jargn"})
+	poller := NewFilePoller(state)
+
+	if diagnostics := getDiagnostics(data, state, logger); len(diagnostics) != 1 {
+		t.Fatalf("Expected the word to be unknown, got %v", diagnostics)
+	}
+
+	if poller.Poll(logger) {
+		t.Error("Expected config files not to be reloaded when they are first seen")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".proof.toml"), []byte("ignoredWords = [\"jargn\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !poller.Poll(logger) {
+		t.Fatal("Expected the created config file to be reloaded")
+	}
+
+	if diagnostics := getDiagnostics(data, state, logger); len(diagnostics) != 0 {
+		t.Errorf("Expected the word to be ignored by the config file, got %v", diagnostics)
+	}
+}
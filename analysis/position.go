@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"proof/lsp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Words and their columns are tracked internally as rune offsets into a
+// line. Columns sent to or received from the client are counted in the code
+// units of the negotiated position encoding and have to be converted with the
+// functions below.
+
+// negotiateEncoding picks the first encoding offered by the client. Clients
+// which don't offer any encoding only support utf-16.
+func negotiateEncoding(offered []lsp.PositionEncodingKind) lsp.PositionEncodingKind {
+	for _, encoding := range offered {
+		switch encoding {
+		case lsp.UTF8, lsp.UTF16, lsp.UTF32:
+			return encoding
+		}
+	}
+
+	return lsp.UTF16
+}
+
+// codeUnits returns the number of code units needed for r in the encoding.
+func codeUnits(r rune, encoding lsp.PositionEncodingKind) int {
+	switch encoding {
+	case lsp.UTF8:
+		return utf8.RuneLen(r)
+
+	case lsp.UTF32:
+		return 1
+
+	default:
+		if r >= 0x10000 {
+			return 2
+		}
+
+		return 1
+	}
+}
+
+// runeColumn converts a column in code units of the encoding into a rune
+// offset into line.
+func runeColumn(line string, column int, encoding lsp.PositionEncodingKind) int {
+	runes := 0
+	units := 0
+
+	for _, r := range line {
+		if units >= column {
+			break
+		}
+
+		units += codeUnits(r, encoding)
+		runes++
+	}
+
+	return runes
+}
+
+// encodedColumn converts a rune offset into line into a column in code units
+// of the encoding.
+func encodedColumn(line string, column int, encoding lsp.PositionEncodingKind) int {
+	units := 0
+
+	for i, r := range []rune(line) {
+		if i >= column {
+			break
+		}
+
+		units += codeUnits(r, encoding)
+	}
+
+	return units
+}
+
+// wordRange converts the rune columns of a word in line into a range in the
+// encoding.
+func wordRange(line string, word Word, encoding lsp.PositionEncodingKind) lsp.Range {
+	return lineRange(
+		word.Row,
+		encodedColumn(line, word.Start, encoding),
+		encodedColumn(line, word.End, encoding))
+}
+
+// lineAt returns the line at row in text.
+func lineAt(text string, row int) (string, bool) {
+	if row < 0 || row > strings.Count(text, "\n") {
+		return "", false
+	}
+
+	start := lineOffset(text, row)
+	end := strings.IndexByte(text[start:], '\n')
+
+	if end == -1 {
+		return text[start:], true
+	}
+
+	return text[start : start+end], true
+}
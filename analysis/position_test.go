@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"testing"
+)
+
+func TestDiagnosticColumnsUseEncoding(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	line := "😀 café wrold"
+
+	cases := []struct {
+		encoding lsp.PositionEncodingKind
+		start    int
+		end      int
+	}{
+		{lsp.UTF32, 7, 12},
+		{lsp.UTF16, 8, 13},
+		{lsp.UTF8, 11, 16},
+	}
+
+	for _, c := range cases {
+		state := newTestState(t, "café", "world")
+		state.PositionEncoding = c.encoding
+
+		diagnostics := getLinesDiagnostics(0, []string{line}, []string{line}, state.Settings, state, logger)
+
+		if len(diagnostics) != 1 {
+			t.Fatalf("%s: expected 1 diagnostic, got %d", c.encoding, len(diagnostics))
+		}
+
+		rng := diagnostics[0].Range
+
+		if rng.Start.Character != c.start || rng.End.Character != c.end {
+			t.Errorf("%s: expected columns %d-%d, got %d-%d",
+				c.encoding, c.start, c.end, rng.Start.Character, rng.End.Character)
+		}
+
+		if column := runeColumn(line, c.start, c.encoding); column != 7 {
+			t.Errorf("%s: expected rune column 7, got %d", c.encoding, column)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	if encoding := negotiateEncoding(nil); encoding != lsp.UTF16 {
+		t.Errorf("Expected utf-16 without offered encodings, got %s", encoding)
+	}
+
+	offered := []lsp.PositionEncodingKind{"utf-7", lsp.UTF8, lsp.UTF16}
+
+	if encoding := negotiateEncoding(offered); encoding != lsp.UTF8 {
+		t.Errorf("Expected utf-8, got %s", encoding)
+	}
+}
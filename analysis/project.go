@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DefaultProjectDictionary is the path of project dictionaries relative to
+// the workspace folder or one of its parents.
+const DefaultProjectDictionary = ".proof/dictionary.txt"
+
+// loadProjectDictionaries finds and reads the project dictionary of every
+// workspace folder. Project dictionaries are shared by everyone working on a
+// project and their words are only known in the documents of the workspace
+// folder they were found for.
+func (s *State) loadProjectDictionaries(logger *log.Logger) {
+	s.projectDictionaries = make(map[string]FileDictionary)
+
+	for _, folder := range s.WorkspaceFolders {
+		s.projectDictionaries[folder] = readProjectDictionary(findProjectDictionary(folder, s.ProjectDictionary), logger)
+	}
+}
+
+func readProjectDictionary(path string, logger *log.Logger) FileDictionary {
+	dictionary, err := ReadFileDictionary(path)
+
+	switch {
+	case err == nil:
+		logger.Printf("Loaded project dictionary: %s", path)
+	case !errors.Is(err, fs.ErrNotExist):
+		logger.Printf("Failed to open project dictionary: %s", err)
+	}
+
+	return dictionary
+}
+
+// reloadProjectDictionaries reads the project dictionaries with the given
+// path again. It reports if any workspace folder uses the dictionary.
+func (s *State) reloadProjectDictionaries(path string, logger *log.Logger) bool {
+	reloaded := false
+
+	for folder, dictionary := range s.projectDictionaries {
+		if filepath.Clean(dictionary.Path) == path {
+			s.projectDictionaries[folder] = readProjectDictionary(path, logger)
+			reloaded = true
+		}
+	}
+
+	return reloaded
+}
+
+// findProjectDictionary looks for the dictionary with the given path in the
+// folder and its parents. The path in the folder is returned if there is
+// none.
+func findProjectDictionary(folder string, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	for dir := folder; ; {
+		path := filepath.Join(dir, name)
+
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+
+		if parent == dir {
+			return filepath.Join(folder, name)
+		}
+
+		dir = parent
+	}
+}
+
+// ProjectDictionaryPath returns the path of the project dictionary of a
+// directory with the default name, which may not exist yet.
+func ProjectDictionaryPath(dir string) string {
+	return findProjectDictionary(dir, DefaultProjectDictionary)
+}
+
+// projectDictionaryFor returns the project dictionary of the innermost
+// workspace folder containing a document.
+func (s *State) projectDictionaryFor(uri string) (FileDictionary, bool) {
+	folder, ok := s.workspaceFolder(uri)
+
+	if !ok {
+		return FileDictionary{}, false
+	}
+
+	dictionary, ok := s.projectDictionaries[folder]
+
+	return dictionary, ok
+}
@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"testing"
+)
+
+func TestProjectDictionaries(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	root := t.TempDir()
+
+	// The first folder is nested in a project with a dictionary, the second
+	// one has none yet
+	project := filepath.Join(root, "project", "folder")
+	other := filepath.Join(root, "other")
+
+	for _, dir := range []string{filepath.Join(root, "project", ".proof"), project, other} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "project", DefaultProjectDictionary), []byte("jargn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := newTestState(t, "a")
+	state.Initialize(lsp.InitializeRequestParams{
+		WorkspaceFolders: []lsp.WorkspaceFolder{{URI: pathToURI(project)}, {URI: pathToURI(other)}},
+	}, logger)
+
+	inProject := createDocumentData(lsp.TextDocumentItem{URI: pathToURI(filepath.Join(project, "a.txt")), LanguageID: "text", Text: "jargn"})
+	inOther := createDocumentData(lsp.TextDocumentItem{URI: pathToURI(filepath.Join(other, "a.txt")), LanguageID: "text", Text: "jargn"})
+
+	if diagnostics := getDiagnostics(inProject, state, logger); len(diagnostics) != 0 {
+		t.Errorf("Expected the project dictionary to be used, got %v", diagnostics)
+	}
+
+	if diagnostics := getDiagnostics(inOther, state, logger); len(diagnostics) != 1 {
+		t.Fatalf("Expected the project dictionary to be used only in its project, got %v", diagnostics)
+	}
+
+	if uri := state.ExecuteCommand("proof.add_to_project_dictionary", []string{inOther.URI, "Jargn"}, logger); uri != inOther.URI {
+		t.Fatalf("Expected the word to be added")
+	}
+
+	if diagnostics := getDiagnostics(inOther, state, logger); len(diagnostics) != 0 {
+		t.Errorf("Expected the added word to be known, got %v", diagnostics)
+	}
+
+	content, err := os.ReadFile(filepath.Join(other, DefaultProjectDictionary))
+
+	if err != nil || string(content) != "jargn\n" {
+		t.Errorf("Expected the project dictionary to be created, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,138 @@
+package analysis
+
+import (
+	"log"
+	"proof/lsp"
+	"sync"
+	"time"
+)
+
+// DiagnosticsScheduler checks documents in the background. Every document
+// gets its own worker which waits for a burst of changes to settle before
+// checking it so that typing doesn't queue up a check for every keystroke.
+type DiagnosticsScheduler struct {
+	state   *State
+	delay   time.Duration
+	check   func(uri string, logger *log.Logger) ([]lsp.Diagnostic, int, bool)
+	publish func(uri string, version int, diagnostics []lsp.Diagnostic)
+	logger  *log.Logger
+
+	mutex   sync.Mutex
+	workers map[string]chan struct{}
+}
+
+func NewDiagnosticsScheduler(
+	state *State,
+	delay time.Duration,
+	publish func(uri string, version int, diagnostics []lsp.Diagnostic),
+	logger *log.Logger) *DiagnosticsScheduler {
+
+	return &DiagnosticsScheduler{
+		state:   state,
+		delay:   delay,
+		check:   state.CheckDocument,
+		publish: publish,
+		logger:  logger,
+		workers: make(map[string]chan struct{}),
+	}
+}
+
+// Schedule requests a check of the document. The check starts once no other
+// check has been requested for the document for the delay of the scheduler.
+func (d *DiagnosticsScheduler) Schedule(uri string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	trigger, ok := d.workers[uri]
+
+	if !ok {
+		trigger = make(chan struct{}, 1)
+		d.workers[uri] = trigger
+
+		go d.work(uri, trigger)
+	}
+
+	select {
+	case trigger <- struct{}{}:
+	default:
+		// A check is already pending
+	}
+}
+
+// ScheduleAll requests a check of all open documents.
+func (d *DiagnosticsScheduler) ScheduleAll() {
+	for _, uri := range d.state.OpenDocuments() {
+		d.Schedule(uri)
+	}
+}
+
+// Stop stops the worker of a document after the document was closed.
+func (d *DiagnosticsScheduler) Stop(uri string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if trigger, ok := d.workers[uri]; ok {
+		close(trigger)
+		delete(d.workers, uri)
+	}
+}
+
+func (d *DiagnosticsScheduler) work(uri string, trigger chan struct{}) {
+	for range trigger {
+		if !d.debounce(trigger) {
+			return
+		}
+
+		diagnostics, version, current := d.check(uri, d.logger)
+
+		if !current {
+			// The document changed while it was checked, so another check
+			// is already scheduled.
+			d.logger.Printf("Discarded outdated diagnostics for %s", uri)
+			continue
+		}
+
+		if !d.publishCurrent(uri, trigger, version, diagnostics) {
+			d.logger.Printf("Discarded diagnostics for closed document %s", uri)
+			return
+		}
+	}
+}
+
+// publishCurrent publishes the diagnostics of a document unless its worker
+// was stopped while the document was checked. Stop waits for the diagnostics
+// to be published, so the diagnostics cleared after closing a document are
+// never overwritten by a worker.
+func (d *DiagnosticsScheduler) publishCurrent(uri string, trigger chan struct{}, version int, diagnostics []lsp.Diagnostic) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.workers[uri] != trigger {
+		return false
+	}
+
+	d.publish(uri, version, diagnostics)
+
+	return true
+}
+
+// debounce waits until no trigger arrived for the delay of the scheduler. It
+// reports false if the worker was stopped in the meantime.
+func (d *DiagnosticsScheduler) debounce(trigger chan struct{}) bool {
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-trigger:
+			if !ok {
+				return false
+			}
+
+			timer.Reset(d.delay)
+
+		case <-timer.C:
+			return true
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerDebouncesChanges(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello", "world")
+	uri := "file:///test.txt"
+
+	mutex := sync.Mutex{}
+	published := []int{}
+
+	scheduler := NewDiagnosticsScheduler(state, 20*time.Millisecond, func(uri string, version int, diagnostics []lsp.Diagnostic) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		published = append(published, version)
+	}, logger)
+
+	state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: "text", Version: 1, Text: "hello"}, logger)
+	scheduler.Schedule(uri)
+
+	for version := 2; version <= 5; version++ {
+		state.UpdateDocument(
+			lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: version},
+			[]lsp.TextDocumentContentChangeEvent{{Text: "hello wrold"}},
+			logger)
+		scheduler.Schedule(uri)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	scheduler.Stop(uri)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(published) != 1 || published[0] != 5 {
+		t.Fatalf("Expected a single publish for version 5, got %v", published)
+	}
+}
+
+func TestSchedulerDiscardsDiagnosticsOfClosedDocuments(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello")
+	uri := "file:///test.txt"
+
+	mutex := sync.Mutex{}
+	published := []int{}
+
+	scheduler := NewDiagnosticsScheduler(state, time.Millisecond, func(uri string, version int, diagnostics []lsp.Diagnostic) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		published = append(published, version)
+	}, logger)
+
+	checking := make(chan struct{})
+	closed := make(chan struct{})
+	done := make(chan struct{})
+
+	// The document is closed after it was checked but before the
+	// diagnostics are published
+	scheduler.check = func(uri string, logger *log.Logger) ([]lsp.Diagnostic, int, bool) {
+		defer close(done)
+
+		diagnostics, version, current := state.CheckDocument(uri, logger)
+		close(checking)
+		<-closed
+
+		return diagnostics, version, current
+	}
+
+	state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: "text", Version: 1, Text: "hello wrold"}, logger)
+	scheduler.Schedule(uri)
+
+	<-checking
+	state.CloseDocument(uri)
+	scheduler.Stop(uri)
+	close(closed)
+	<-done
+
+	time.Sleep(20 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(published) != 0 {
+		t.Fatalf("Expected no diagnostics to be published after closing the document, got %v", published)
+	}
+}
@@ -0,0 +1,209 @@
+package analysis
+
+import (
+	"log"
+	"proof/lsp"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Settings are the effective settings used to check a document. They are
+// resolved from the defaults, the initializationOptions, the global settings
+// of the client and the settings of the workspace folder and document the
+// client scoped them to.
+type Settings struct {
+	DictionaryPath       string
+	ProjectDictionary    string
+	AllowImplicitPlurals bool
+	MaxErrors            int
+	MaxSuggestions       int
+	IgnoredWords         []string
+	ForbiddenWords       []string
+	IgnoredPatterns      []string
+	IgnoredWordPatterns  []string
+	ExcludedFilePatterns []string
+	ExcludedFileTypes    []string
+	CheckedRegions       map[string][]string
+	CheckCodeBlocks      bool
+	SkippedTokens        map[string]bool
+	Severity             lsp.DiagnosticSeverity
+
+	ignoredWords   WordSet
+	forbiddenWords WordSet
+
+	// The words declared by proof:words directives in the checked document.
+	documentWords WordSet
+
+	// The words of the project dictionary of the checked document.
+	projectWords WordSet
+
+	// The compiled patterns, which are only set for settings resolved by the
+	// state.
+	ignoredPatterns      []*regexp.Regexp
+	ignoredWordPatterns  []*regexp.Regexp
+	excludedFilePatterns []*regexp.Regexp
+}
+
+const (
+	DefaultMaxErrors      = 2
+	DefaultMaxSuggestions = 5
+	DefaultSeverity       = lsp.Hint
+)
+
+// severities are the diagnostic severities by their name in the settings.
+var severities = map[string]lsp.DiagnosticSeverity{
+	"error":       lsp.Error,
+	"warning":     lsp.Warning,
+	"information": lsp.Information,
+	"hint":        lsp.Hint,
+}
+
+func DefaultSettings() Settings {
+	return resolveSettings(lsp.ProofSettings{})
+}
+
+// resolveSettings fills the fields which were not set by the client with
+// the defaults.
+func resolveSettings(proof lsp.ProofSettings) Settings {
+	settings := Settings{
+		ProjectDictionary:    DefaultProjectDictionary,
+		MaxErrors:            DefaultMaxErrors,
+		MaxSuggestions:       DefaultMaxSuggestions,
+		Severity:             DefaultSeverity,
+		IgnoredWords:         proof.IgnoredWords,
+		ForbiddenWords:       proof.ForbiddenWords,
+		IgnoredPatterns:      proof.IgnoredPatterns,
+		IgnoredWordPatterns:  proof.IgnoredWordPatterns,
+		ExcludedFilePatterns: proof.ExcludedFilePatterns,
+		ExcludedFileTypes:    proof.ExcludedFileTypes,
+		CheckedRegions:       proof.CheckedRegions,
+	}
+
+	if proof.DictionaryPath != nil {
+		settings.DictionaryPath = *proof.DictionaryPath
+	}
+
+	if proof.ProjectDictionary != nil {
+		settings.ProjectDictionary = *proof.ProjectDictionary
+	}
+
+	if proof.AllowImplicitPlurals != nil {
+		settings.AllowImplicitPlurals = *proof.AllowImplicitPlurals
+	}
+
+	if proof.MaxErrors != nil {
+		settings.MaxErrors = *proof.MaxErrors
+	}
+
+	if proof.MaxSuggestions != nil {
+		settings.MaxSuggestions = *proof.MaxSuggestions
+	}
+
+	if proof.CheckCodeBlocks != nil {
+		settings.CheckCodeBlocks = *proof.CheckCodeBlocks
+	}
+
+	if proof.Severity != nil {
+		if severity, ok := severities[*proof.Severity]; ok {
+			settings.Severity = severity
+		}
+	}
+
+	settings.SkippedTokens = map[string]bool{}
+
+	for _, detector := range tokenDetectors {
+		settings.SkippedTokens[detector.name] = true
+	}
+
+	for name, skipped := range proof.SkippedTokens {
+		settings.SkippedTokens[name] = skipped
+	}
+
+	settings.ignoredWords = wordSet(settings.IgnoredWords)
+	settings.forbiddenWords = wordSet(settings.ForbiddenWords)
+
+	return settings
+}
+
+// settingsFor resolves the settings of a document. Settings scoped to the
+// document take precedence over the settings of the innermost workspace
+// folder containing it, which take precedence over the global settings. The
+// config files of the repository take precedence over all settings of the
+// client, as many clients answer scoped requests with their global settings.
+// The dictionaries and maxErrors are shared by all documents, so they are
+// always taken from the global settings.
+func (s *State) settingsFor(uri string) Settings {
+	proof := s.initializationSettings.Merge(s.proofSettings)
+	folder := ""
+
+	for scope := range s.scopedSettings {
+		if strings.HasPrefix(uri, strings.TrimSuffix(scope, "/")+"/") && len(scope) > len(folder) {
+			folder = scope
+		}
+	}
+
+	if folder != "" {
+		proof = proof.Merge(s.scopedSettings[folder])
+	}
+
+	if scoped, ok := s.scopedSettings[uri]; ok {
+		proof = proof.Merge(scoped)
+	}
+
+	proof = proof.Merge(s.repositorySettings(uri))
+	settings := s.withPatterns(resolveSettings(proof))
+	settings.DictionaryPath = s.DictionaryPath
+	settings.ProjectDictionary = s.ProjectDictionary
+	settings.MaxErrors = s.MaxErrors
+
+	if dictionary, ok := s.projectDictionaryFor(uri); ok {
+		settings.projectWords = dictionary.WordSet
+	}
+
+	return settings
+}
+
+// ConfigurationScopes returns the uris of the workspace folders and open
+// documents which settings can be scoped to.
+func (s *State) ConfigurationScopes() []string {
+	scopes := []string{}
+
+	for _, folder := range s.WorkspaceFolders {
+		scopes = append(scopes, pathToURI(folder))
+	}
+
+	return append(scopes, s.OpenDocuments()...)
+}
+
+// UpdateConfiguration stores settings pulled from the client. The global
+// settings are kept if global is nil. The scoped settings replace the
+// settings of the same scopes. It reports if any settings changed.
+func (s *State) UpdateConfiguration(global *lsp.ProofSettings, scoped map[string]lsp.ProofSettings, logger *log.Logger) bool {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	changed := false
+
+	for scope, settings := range scoped {
+		if previous, ok := s.scopedSettings[scope]; ok && reflect.DeepEqual(previous, settings) {
+			continue
+		}
+
+		s.compilePatterns(settings)
+
+		s.scopedSettings[scope] = settings
+		changed = true
+	}
+
+	if global != nil && !reflect.DeepEqual(*global, s.proofSettings) {
+		s.applySettings(*global, logger)
+		return true
+	}
+
+	if changed {
+		s.generation++
+	}
+
+	return changed
+}
@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/agnivade/levenshtein"
+	"github.com/f1monkey/spellchecker"
+)
+
+// spellcheckerLayers are the word lists shared by all documents which the
+// spellchecker is built from. The project dictionaries and the settings of
+// other scopes are checked separately, see isKnown.
+type spellcheckerLayers struct {
+	builtin      []string
+	dictionary   map[string]struct{}
+	ignoredWords map[string]struct{}
+}
+
+// includes reports if a spellchecker built from the layers knows all words of
+// the other layers, so that it can be extended instead of rebuilt.
+func (l spellcheckerLayers) includes(other spellcheckerLayers) bool {
+	if len(l.builtin) != len(other.builtin) {
+		return false
+	}
+
+	return hasAll(l.dictionary, other.dictionary) && hasAll(l.ignoredWords, other.ignoredWords)
+}
+
+func (l spellcheckerLayers) words() []string {
+	words := slices.Clone(l.builtin)
+	words = slices.AppendSeq(words, maps.Keys(l.dictionary))
+
+	return slices.AppendSeq(words, maps.Keys(l.ignoredWords))
+}
+
+func buildSpellchecker(layers spellcheckerLayers) (*spellchecker.Spellchecker, error) {
+	sc, err := spellchecker.New(spellchecker.DefaultAlphabet)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sc.Add(layers.words()...)
+
+	return sc, nil
+}
+
+// checker returns the spellchecker currently used. It is replaced as a whole
+// when it is rebuilt.
+func (s *State) checker() *spellchecker.Spellchecker {
+	return s.spellchecker.Load()
+}
+
+// OnSpellcheckerRebuilt sets the function called after the spellchecker was
+// rebuilt in the background, which is when the diagnostics of the open
+// documents have to be updated.
+func (s *State) OnSpellcheckerRebuilt(fn func()) {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	s.rebuilt = fn
+}
+
+// suggest returns the known words closest to a word. The spellchecker only
+// uses its default of maxErrors when searching, whichever option it was
+// built with, so suggestions further away than maxErrors are dropped here.
+func (s *State) suggest(word string, settings Settings) []string {
+	suggestions := s.dictionaries(settings).Suggest(word, settings.MaxSuggestions)
+
+	return slices.DeleteFunc(suggestions, func(suggestion string) bool {
+		return levenshtein.ComputeDistance(word, suggestion) > settings.MaxErrors
+	})
+}
+
+// updateSpellchecker brings the spellchecker in line with the current
+// dictionary and ignoredWords. Added words are added to the spellchecker
+// right away. Removed words require a new spellchecker, which is built in
+// the background while the old one is still used and replaces it once it is
+// ready.
+func (s *State) updateSpellchecker(logger *log.Logger) {
+	layers := spellcheckerLayers{
+		builtin:      s.builtinWords,
+		dictionary:   maps.Clone(s.userDictionary.WordSet),
+		ignoredWords: wordSet(s.IgnoredWords),
+	}
+
+	if !s.rebuilding && layers.includes(s.layers) {
+		s.checker().Add(slices.Concat(added(s.layers.dictionary, layers.dictionary), added(s.layers.ignoredWords, layers.ignoredWords))...)
+		s.layers = layers
+		return
+	}
+
+	// The result of a rebuild started earlier is dropped
+	s.rebuilds++
+	s.rebuilding = true
+	s.layers = layers
+	rebuild := s.rebuilds
+
+	logger.Print("Rebuilding spellchecker")
+
+	go func() {
+		sc, err := buildSpellchecker(layers)
+
+		s.settingsMutex.Lock()
+
+		if err != nil || rebuild != s.rebuilds {
+			s.settingsMutex.Unlock()
+
+			if err != nil {
+				logger.Printf("Failed to rebuild spellchecker: %s", err)
+			}
+
+			return
+		}
+
+		s.spellchecker.Store(sc)
+		s.rebuilding = false
+		s.generation++
+		rebuilt := s.rebuilt
+
+		s.settingsMutex.Unlock()
+
+		logger.Print("Rebuilt spellchecker")
+
+		if rebuilt != nil {
+			rebuilt()
+		}
+	}()
+}
+
+// added returns the words of a set which are not in the previous set.
+func added(previous map[string]struct{}, set map[string]struct{}) []string {
+	words := []string{}
+
+	for word := range set {
+		if _, ok := previous[word]; !ok {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+func hasAll(set map[string]struct{}, other map[string]struct{}) bool {
+	for word := range other {
+		if _, ok := set[word]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"testing"
+	"time"
+)
+
+func TestSpellcheckerIsRebuiltWhenWordsAreRemoved(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello")
+	rebuilt := make(chan struct{}, 1)
+	state.OnSpellcheckerRebuilt(func() { rebuilt <- struct{}{} })
+
+	known := func(word string) bool {
+		_, ok := state.matchWord(word, state.settingsFor("file:///a.txt"))
+		return ok
+	}
+
+	// Added words are known right away
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{IgnoredWords: []string{"jargn", "blorf"}}}, logger)
+
+	if !known("jargn") || !known("blorf") {
+		t.Fatal("Expected the ignored words to be known")
+	}
+
+	select {
+	case <-rebuilt:
+		t.Fatal("Expected no rebuild for added words")
+	default:
+	}
+
+	// Removed words are known until the rebuilt spellchecker replaces the old one
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{IgnoredWords: []string{"blorf"}}}, logger)
+
+	select {
+	case <-rebuilt:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the spellchecker to be rebuilt")
+	}
+
+	if known("jargn") || !known("blorf") || !known("hello") {
+		t.Error("Expected only the removed word to be unknown")
+	}
+
+	// Changing maxErrors takes effect without a rebuild
+	maxErrors := 1
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{MaxErrors: &maxErrors}}, logger)
+
+	if suggestions := state.suggest("hellooo", state.settingsFor("file:///a.txt")); len(suggestions) != 0 {
+		t.Errorf("Expected words further away than maxErrors not to be suggested, got %v", suggestions)
+	}
+
+	if suggestions := state.suggest("hallo", state.settingsFor("file:///a.txt")); len(suggestions) != 1 {
+		t.Errorf("Expected words within maxErrors to be suggested, got %v", suggestions)
+	}
+}
+
+func TestClearedDictionaryPathForgetsWords(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	path := filepath.Join(t.TempDir(), "dictionary.txt")
+
+	if err := os.WriteFile(path, []byte("jargn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := newTestState(t, "hello")
+	rebuilt := make(chan struct{}, 1)
+	state.OnSpellcheckerRebuilt(func() { rebuilt <- struct{}{} })
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{DictionaryPath: &path}}, logger)
+
+	settings := state.settingsFor("file:///a.txt")
+
+	if match, ok := state.matchWord("jargn", settings); !ok || match.Source != sourceDictionary {
+		t.Fatalf("Expected the word to be known from the user dictionary, got %v", match)
+	}
+
+	state.UpdateSettings(lsp.Settings{}, logger)
+
+	select {
+	case <-rebuilt:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the spellchecker to be rebuilt")
+	}
+
+	if match, ok := state.matchWord("jargn", state.settingsFor("file:///a.txt")); ok {
+		t.Errorf("Expected the word to be unknown without a dictionary, got %v", match)
+	}
+}
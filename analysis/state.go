@@ -1,8 +1,10 @@
 package analysis
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -10,19 +12,75 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode"
 
 	"github.com/f1monkey/spellchecker"
 )
 
 type State struct {
-	Spellchecker         *spellchecker.Spellchecker
-	DictionaryPath       string
-	AllowImplicitPlurals bool
-	Documents            map[string]documentData
-	MaxSuggestions       int
-	ExcludedFilePatterns []string
-	ExcludedFileTypes    []string
+	// The global settings. Use settingsFor to get the settings of a document.
+	Settings
+
+	Documents        map[string]documentData
+	Client           lsp.ClientCapabilities
+	PositionEncoding lsp.PositionEncodingKind
+	WorkspaceFolders []string
+
+	// Dictionaries are looked up after the user dictionary and before the
+	// built-in word list, for example word lists of a domain. They have to
+	// be set before the state is used concurrently.
+	Dictionaries []DictionaryLayer
+
+	// documentsMutex guards Documents. When both mutexes are needed it has to
+	// be locked before settingsMutex.
+	documentsMutex sync.Mutex
+
+	// settingsMutex guards the settings, the word sets and the generation.
+	// Functions which aren't exported expect the caller to hold it.
+	settingsMutex sync.RWMutex
+
+	// Incremented whenever the settings or dictionaries change so that
+	// result ids of previously computed diagnostics become stale.
+	generation int
+
+	// The settings as sent by the client. The initializationOptions are
+	// overridden by the settings pushed or pulled later on. Scoped settings
+	// are keyed by the uri of the workspace folder or document they apply to.
+	initializationSettings lsp.ProofSettings
+	proofSettings          lsp.ProofSettings
+	scopedSettings         map[string]lsp.ProofSettings
+
+	userDictionary FileDictionary
+
+	// The spellchecker knows the built-in words, the words of the user
+	// dictionary and the global ignoredWords. It is replaced when it has to
+	// be rebuilt, see updateSpellchecker. layers are the words it contains
+	// or will contain once a pending rebuild is done. The built-in words are
+	// sorted.
+	spellchecker atomic.Pointer[spellchecker.Spellchecker]
+	builtinWords []string
+	layers       spellcheckerLayers
+	rebuilds     int
+	rebuilding   bool
+	rebuilt      func()
+
+	// The project dictionaries by the workspace folder they were found for.
+	projectDictionaries map[string]FileDictionary
+
+	// The changes made to the dictionaries by commands, the last one last.
+	dictionaryChanges []dictionaryChange
+
+	// cacheMutex guards what is read lazily while the settings are only
+	// locked for reading: the compiled patterns of all settings by their
+	// source, nil for the ones which failed to compile, the config files by
+	// their directory and the errors found in them which weren't reported
+	// yet. It has to be locked after settingsMutex.
+	cacheMutex     sync.Mutex
+	patterns       map[string]*regexp.Regexp
+	configFiles    map[string]*configFile
+	settingsErrors []error
 }
 
 type documentData struct {
@@ -30,51 +88,109 @@ type documentData struct {
 	LanguageID  string
 	Extension   string
 	Text        string
+	Version     int
 	Diagnostics []lsp.Diagnostic
+	ResultID    string
+
+	// Set when the diagnostics have not been computed for the current text.
+	Stale bool
 }
 
-func NewState(sc *spellchecker.Spellchecker) State {
-	const DefaultMaxSuggestions = 5
+// NewState creates the state with a spellchecker knowing the built-in words.
+func NewState(words []string) (*State, error) {
+	s := &State{
+		Settings:            DefaultSettings(),
+		builtinWords:        slices.Sorted(slices.Values(words)),
+		Documents:           make(map[string]documentData),
+		PositionEncoding:    lsp.UTF16,
+		scopedSettings:      make(map[string]lsp.ProofSettings),
+		userDictionary:      FileDictionary{WordSet: WordSet{}},
+		projectDictionaries: make(map[string]FileDictionary),
+		patterns:            make(map[string]*regexp.Regexp),
+		configFiles:         make(map[string]*configFile),
+	}
+
+	s.layers = spellcheckerLayers{builtin: s.builtinWords}
+	sc, err := buildSpellchecker(s.layers)
 
-	return State{
-		Spellchecker:   sc,
-		MaxSuggestions: DefaultMaxSuggestions,
-		Documents:      make(map[string]documentData),
+	if err != nil {
+		return nil, err
 	}
-}
 
-// Workspace
+	s.spellchecker.Store(sc)
 
-func (s *State) UpdateSettings(settings lsp.Settings, logger *log.Logger) {
-	s.AllowImplicitPlurals = settings.Proof.AllowImplicitPlurals
-	s.MaxSuggestions = settings.Proof.MaxSuggestions
-	s.DictionaryPath = settings.Proof.DictionaryPath
-	s.ExcludedFilePatterns = settings.Proof.ExcludedFilePatterns
-	s.ExcludedFileTypes = settings.Proof.ExcludedFileTypes
+	return s, nil
+}
 
-	s.Spellchecker.WithOpts(spellchecker.WithMaxErrors(settings.Proof.MaxErrors))
+// Lifecycle
 
-	reader := strings.NewReader(strings.Join(settings.Proof.IgnoredWords, "\n"))
-	s.Spellchecker.AddFrom(reader)
+// Initialize stores the capabilities of the client and the settings sent as
+// initializationOptions. It has to be called before any other method of the
+// state is used concurrently.
+func (s *State) Initialize(params lsp.InitializeRequestParams, logger *log.Logger) {
+	s.Client = params.Capabilities
+	s.PositionEncoding = negotiateEncoding(s.Client.PositionEncodings())
+	s.WorkspaceFolders = []string{}
 
-	if s.DictionaryPath != "" {
-		if err := ensureDir(s.DictionaryPath); err != nil {
-			logger.Printf("Failed to create dictionary directory: %s", err)
+	for _, folder := range params.WorkspaceFolders {
+		if path, ok := uriToPath(folder.URI); ok {
+			s.WorkspaceFolders = append(s.WorkspaceFolders, path)
 		}
+	}
 
-		file, err := os.Open(s.DictionaryPath)
-
-		if err != nil {
-			logger.Printf("Failed to open dictionary file: %s", err)
-			return
+	if len(s.WorkspaceFolders) == 0 {
+		if path, ok := uriToPath(params.RootURI); ok {
+			s.WorkspaceFolders = append(s.WorkspaceFolders, path)
+		} else if params.RootPath != "" {
+			s.WorkspaceFolders = append(s.WorkspaceFolders, params.RootPath)
 		}
+	}
+
+	logger.Printf("Client capabilities | PositionEncoding: %s | PullDiagnostics: %v | DiagnosticRefresh: %v | CodeActionLiterals: %v | WorkspaceFolders: %v",
+		s.PositionEncoding,
+		s.Client.SupportsPullDiagnostics(),
+		s.Client.SupportsDiagnosticRefresh(),
+		s.Client.SupportsCodeActionLiterals(),
+		s.WorkspaceFolders)
 
-		defer file.Close()
+	settings, err := params.InitializationSettings()
 
-		reader := bufio.NewReader(file)
-		s.Spellchecker.AddFrom(reader)
+	if err != nil {
+		logger.Printf("Invalid initializationOptions: %s", err)
+		settings = lsp.ProofSettings{}
 	}
 
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	s.initializationSettings = settings
+	s.applySettings(lsp.ProofSettings{}, logger)
+}
+
+// Workspace
+
+// UpdateSettings replaces the global settings with the settings pushed by the
+// client. Fields which are not set fall back to the initializationOptions
+// and then to the defaults.
+func (s *State) UpdateSettings(settings lsp.Settings, logger *log.Logger) {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	s.applySettings(settings.Proof, logger)
+}
+
+func (s *State) applySettings(proof lsp.ProofSettings, logger *log.Logger) {
+	merged := s.initializationSettings.Merge(proof)
+	s.compilePatterns(merged)
+
+	s.proofSettings = proof
+	s.Settings = s.withPatterns(resolveSettings(merged))
+	s.generation++
+
+	s.loadDictionary(logger)
+	s.loadProjectDictionaries(logger)
+	s.updateSpellchecker(logger)
+
 	logger.Printf(
 		"Updated Settings "+
 			"| AllowImplicitPlurals: %v "+
@@ -87,112 +203,434 @@ func (s *State) UpdateSettings(settings lsp.Settings, logger *log.Logger) {
 		s.AllowImplicitPlurals,
 		s.DictionaryPath,
 		s.MaxSuggestions,
-		settings.Proof.MaxErrors,
-		settings.Proof.IgnoredWords,
+		s.MaxErrors,
+		s.IgnoredWords,
 		s.ExcludedFilePatterns,
 		s.ExcludedFileTypes)
 }
 
-func (s *State) ExecuteCommand(command string, arguments []string, logger *log.Logger) (string, []lsp.Diagnostic) {
+// ReloadDictionary reads the user or project dictionary file again after it
+// was changed outside of proof. It reports if the uri is the one of a
+// dictionary file.
+func (s *State) ReloadDictionary(uri string, logger *log.Logger) bool {
+	path, ok := uriToPath(uri)
+
+	if !ok {
+		return false
+	}
+
+	return s.reloadDictionaryFile(filepath.Clean(path), logger)
+}
+
+func (s *State) reloadDictionaryFile(path string, logger *log.Logger) bool {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	reloaded := s.reloadProjectDictionaries(path, logger)
+
+	if s.DictionaryPath != "" && path == filepath.Clean(s.DictionaryPath) {
+		s.loadDictionary(logger)
+		s.updateSpellchecker(logger)
+		reloaded = true
+	}
+
+	if !reloaded {
+		return false
+	}
+
+	s.generation++
+	logger.Printf("Reloaded dictionary: %s", path)
+
+	return true
+}
+
+// DictionaryPaths returns the paths of the user dictionary and the project
+// dictionaries of all workspace folders, which may not exist yet.
+func (s *State) DictionaryPaths() []string {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
+	paths := []string{}
+
+	if s.DictionaryPath != "" {
+		paths = append(paths, filepath.Clean(s.DictionaryPath))
+	}
+
+	for _, dictionary := range s.projectDictionaries {
+		paths = append(paths, filepath.Clean(dictionary.Path))
+	}
+
+	slices.Sort(paths)
+
+	return slices.Compact(paths)
+}
+
+// loadDictionary reads the user dictionary, which is empty without a
+// dictionary path. The caller has to update the spellchecker afterwards.
+func (s *State) loadDictionary(logger *log.Logger) {
+	if s.DictionaryPath == "" {
+		s.userDictionary = FileDictionary{WordSet: WordSet{}}
+		return
+	}
+
+	if err := ensureDir(s.DictionaryPath); err != nil {
+		logger.Printf("Failed to create dictionary directory: %s", err)
+	}
+
+	dictionary, err := ReadFileDictionary(s.DictionaryPath)
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Printf("Failed to open dictionary file: %s", err)
+		return
+	}
+
+	s.userDictionary = dictionary
+}
+
+// ExecuteCommand runs a command and returns the uri of the document it was
+// run for if the diagnostics of the open documents have to be updated.
+func (s *State) ExecuteCommand(command string, arguments []string, logger *log.Logger) string {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
 	switch command {
-	case "proof.add_to_dictionary":
+	case "proof.add_to_dictionary", "proof.add_to_project_dictionary":
 		if len(arguments) < 2 {
-			logger.Print("No arguments provided for 'proof.add_to_dictionary'")
-			return "", []lsp.Diagnostic{}
+			logger.Printf("No arguments provided for '%s'", command)
+			return ""
 		}
 
-		uri := arguments[0]
-		word := strings.ToLower(arguments[1])
-
-		s.Spellchecker.Add(word)
+		change := dictionaryChange{
+			uri:          arguments[0],
+			word:         strings.ToLower(arguments[1]),
+			added:        true,
+			dictionaries: []string{"user"},
+		}
 
-		if s.DictionaryPath != "" {
-			if err := ensureDir(s.DictionaryPath); err != nil {
-				logger.Printf("Failed to create dictionary directory: %s", err)
-				return "", []lsp.Diagnostic{}
-			}
+		if command == "proof.add_to_project_dictionary" {
+			change.dictionaries = []string{"project"}
+		}
 
-			file, err := os.OpenFile(s.DictionaryPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+		return s.changeDictionaries(change, true, logger)
 
-			if err != nil {
-				logger.Printf("Failed to open dictionary file: %s", err)
-				return "", []lsp.Diagnostic{}
-			}
+	case "proof.remove_from_dictionary":
+		if len(arguments) < 2 {
+			logger.Printf("No arguments provided for '%s'", command)
+			return ""
+		}
 
-			defer file.Close()
+		// The word is removed from the given dictionary, or from all
+		// dictionaries containing it
+		change := dictionaryChange{uri: arguments[0], word: strings.ToLower(arguments[1])}
 
-			written_bytes, err := file.WriteString(word + "\n")
+		for _, name := range []string{"user", "project"} {
+			dictionary, ok := s.dictionaryNamed(change.uri, name)
 
-			if err != nil {
-				logger.Printf("Failed to write to dictionary file: %s", err)
-				return "", []lsp.Diagnostic{}
+			if ok && dictionary.Contains(change.word) && (len(arguments) < 3 || arguments[2] == name) {
+				change.dictionaries = append(change.dictionaries, name)
 			}
+		}
 
-			bytes := []byte(word + "\n")
+		if len(change.dictionaries) == 0 {
+			logger.Printf("'%s' is in no dictionary", change.word)
+			return ""
+		}
 
-			if written_bytes != len(bytes) {
-				logger.Printf("Failed to write all bytes to dictionary file. written: %d | expected %d", written_bytes, len(bytes))
-				return "", []lsp.Diagnostic{}
-			}
+		return s.changeDictionaries(change, true, logger)
+
+	case "proof.undo_last_dictionary_change":
+		if len(s.dictionaryChanges) == 0 {
+			logger.Print("No dictionary change to undo")
+			return ""
 		}
 
-		logger.Printf("Added '%s' to dictionary", word)
-		return uri, getDiagnosticsForFile(uri, s, logger)
+		change := s.dictionaryChanges[len(s.dictionaryChanges)-1]
+		s.dictionaryChanges = s.dictionaryChanges[:len(s.dictionaryChanges)-1]
+		change.added = !change.added
+
+		return s.changeDictionaries(change, false, logger)
 
 	default:
 		logger.Printf("Unknown command: %s", command)
-		return "", []lsp.Diagnostic{}
+		return ""
 	}
 }
 
+// dictionaryChange is a word added to or removed from the user or project
+// dictionaries by a command.
+type dictionaryChange struct {
+	uri          string
+	word         string
+	added        bool
+	dictionaries []string
+}
+
+// changeDictionaries adds or removes the word of a change and returns the uri
+// the change was made for. Changes which can be undone are remembered.
+func (s *State) changeDictionaries(change dictionaryChange, undoable bool, logger *log.Logger) string {
+	for _, name := range change.dictionaries {
+		dictionary, ok := s.dictionaryNamed(change.uri, name)
+
+		if !ok {
+			logger.Printf("No %s dictionary for %s", name, change.uri)
+			return ""
+		}
+
+		var err error
+
+		if change.added {
+			err = dictionary.Add(change.word)
+		} else {
+			err = dictionary.Remove(change.word)
+		}
+
+		s.updateSpellchecker(logger)
+		s.generation++
+
+		if err != nil {
+			logger.Printf("Failed to update %s dictionary file: %s", name, err)
+			return ""
+		}
+
+		if change.added {
+			logger.Printf("Added '%s' to %s dictionary %s", change.word, name, dictionary.Path)
+		} else {
+			logger.Printf("Removed '%s' from %s dictionary %s", change.word, name, dictionary.Path)
+		}
+	}
+
+	if undoable {
+		s.dictionaryChanges = append(s.dictionaryChanges, change)
+	}
+
+	return change.uri
+}
+
+// dictionaryNamed returns the user dictionary or the project dictionary of a
+// document.
+func (s *State) dictionaryNamed(uri string, name string) (FileDictionary, bool) {
+	if name == "project" {
+		return s.projectDictionaryFor(uri)
+	}
+
+	return s.userDictionary, true
+}
+
 // Documents
 
-func (s *State) OpenDocument(document lsp.TextDocumentItem, logger *log.Logger) ([]lsp.Diagnostic, bool) {
-	uri := document.URI
+// OpenDocument stores a document opened by the client and reports if it
+// should be checked. The diagnostics of the document are computed later by
+// Diagnostic or CheckDocument.
+func (s *State) OpenDocument(document lsp.TextDocumentItem, logger *log.Logger) bool {
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
 	data := createDocumentData(document)
-	diagnostics := getDiagnostics(data, s, logger)
-	data.Diagnostics = diagnostics
+	data.Stale = true
 
 	if data.isExcluded(s, logger) {
-		return []lsp.Diagnostic{}, false
+		return false
 	}
 
-	s.Documents[uri] = data
+	s.Documents[document.URI] = data
 
-	return diagnostics, true
+	return true
 }
 
-func (s *State) UpdateDocument(identifier lsp.VersionedTextDocumentIdentifier, change string, logger *log.Logger) ([]lsp.Diagnostic, bool) {
+// UpdateDocument applies changes to a document and reports if it should be
+// checked. Diagnostics for the lines touched by incremental changes are
+// updated right away while full changes leave the diagnostics stale.
+func (s *State) UpdateDocument(identifier lsp.VersionedTextDocumentIdentifier, changes []lsp.TextDocumentContentChangeEvent, logger *log.Logger) bool {
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
 	uri := identifier.URI
-	document := s.Documents[uri]
-	currentDiagnostics := document.Diagnostics
-	data := updateDocumentData(document, change)
-	diagnostics := getDiagnostics(data, s, logger)
-	data.Diagnostics = diagnostics
+	data, ok := s.Documents[uri]
 
-	if data.isExcluded(s, logger) {
-		return []lsp.Diagnostic{}, false
+	if !ok || data.isExcluded(s, logger) {
+		return false
+	}
+
+	for _, change := range changes {
+		if change.Range == nil {
+			data = updateDocumentData(data, change.Text, identifier.Version)
+			data.Stale = true
+			continue
+		}
+
+		text, first, last, delta := applyChange(data.Text, *change.Range, change.Text, s.PositionEncoding)
+		stale := data.Stale
+		diagnostics := data.Diagnostics
+
+		// Directives may affect any line and a change in a language with an
+		// extractor may affect the regions of the following lines, so the
+		// whole document has to be checked again. In markdown only changes
+		// to the delimiters of blocks spanning several lines do that.
+		removed := linesBetween(data.Text, min(change.Range.Start.Line, change.Range.End.Line), max(change.Range.Start.Line, change.Range.End.Line))
+		markdown := data.LanguageID == "markdown" && !touchesMarkdownDelimiter(removed) && !touchesMarkdownDelimiter(linesBetween(text, first, last))
+
+		if hasDirectives(data.Text) || hasDirectives(change.Text) || (hasExtractor(data.LanguageID) && !markdown) {
+			stale = true
+		}
+
+		if !stale && markdown {
+			document := updateDocumentData(data, text, identifier.Version)
+			settings := s.settingsFor(uri)
+			blockFirst, blockLast := markdownBlock(text, first, last)
+			checked, _ := s.checkedText(document, settings, logger)
+
+			before, after := shiftDiagnostics(diagnostics, blockFirst, blockLast-delta, delta)
+			changed := getLinesDiagnostics(blockFirst, linesBetween(text, blockFirst, blockLast), linesBetween(checked, blockFirst, blockLast), settings, s, logger)
+			diagnostics = append(append(before, changed...), after...)
+		} else if !stale {
+			lines := linesBetween(text, first, last)
+			before, after := shiftDiagnostics(diagnostics, change.Range.Start.Line, change.Range.End.Line, delta)
+			changed := getLinesDiagnostics(first, lines, lines, s.settingsFor(uri), s, logger)
+			diagnostics = append(append(before, changed...), after...)
+		}
+
+		data = updateDocumentData(data, text, identifier.Version)
+		data.Diagnostics = diagnostics
+		data.Stale = stale
+	}
+
+	if !data.Stale {
+		data.ResultID = s.resultID(data)
 	}
 
 	s.Documents[uri] = data
 
-	return diagnostics, !sliceEqual(currentDiagnostics, diagnostics)
+	return true
+}
+
+func (s *State) CloseDocument(uri string) {
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	delete(s.Documents, uri)
+	delete(s.scopedSettings, uri)
 }
 
-func (s *State) Diagnostic(uri string, logger *log.Logger) ([]lsp.Diagnostic, bool) {
-	data := s.Documents[uri]
-	diagnostics := getDiagnosticsForFile(uri, s, logger)
+// Diagnostic computes a diagnostic report for a document requested by the
+// client. If the client already has the diagnostics for the current version
+// of the document an unchanged report is returned instead.
+func (s *State) Diagnostic(uri string, previousResultID string, logger *log.Logger) (lsp.DocumentDiagnosticReportKind, []lsp.Diagnostic, string) {
+	data, ok := s.document(uri)
+
+	if !ok {
+		return lsp.Full, []lsp.Diagnostic{}, ""
+	}
 
-	if sliceEqual(data.Diagnostics, diagnostics) {
-		return []lsp.Diagnostic{}, false
+	s.settingsMutex.RLock()
+	resultID := s.resultID(data)
+	s.settingsMutex.RUnlock()
+
+	if previousResultID != "" && previousResultID == resultID {
+		return lsp.Unchanged, nil, resultID
 	}
 
-	data.Diagnostics = diagnostics
-	s.Documents[uri] = data
+	data, _ = s.diagnose(data, logger)
 
-	return diagnostics, true
+	return lsp.Full, s.clientDiagnostics(uri, data.Diagnostics), data.ResultID
 }
+
+// CheckDocument makes sure the diagnostics of a document are up to date. It
+// reports false if the document was closed or changed while it was checked,
+// in which case the diagnostics are outdated and should be discarded.
+func (s *State) CheckDocument(uri string, logger *log.Logger) ([]lsp.Diagnostic, int, bool) {
+	data, ok := s.document(uri)
+
+	if !ok {
+		return nil, 0, false
+	}
+
+	data, current := s.diagnose(data, logger)
+
+	return s.clientDiagnostics(uri, data.Diagnostics), data.Version, current
+}
+
+// OpenDocuments returns the uris of all documents opened by the client.
+func (s *State) OpenDocuments() []string {
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	uris := make([]string, 0, len(s.Documents))
+
+	for uri := range s.Documents {
+		uris = append(uris, uri)
+	}
+
+	return uris
+}
+
+// document returns a copy of an open document which can be used without
+// holding documentsMutex.
+func (s *State) document(uri string) (documentData, bool) {
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	data, ok := s.Documents[uri]
+
+	return data, ok
+}
+
+// diagnose computes the diagnostics of a copy of a document unless they are
+// up to date. Only the settings are locked while checking so that the
+// document can be changed in the meantime. The diagnostics are stored if the
+// document is still at the same version afterwards, which is reported by the
+// second return value.
+func (s *State) diagnose(data documentData, logger *log.Logger) (documentData, bool) {
+	s.settingsMutex.RLock()
+	resultID := s.resultID(data)
+
+	if !data.Stale && data.ResultID == resultID {
+		s.settingsMutex.RUnlock()
+		return data, true
+	}
+
+	data.Diagnostics = getDiagnostics(data, s, logger)
+	data.ResultID = resultID
+	data.Stale = false
+	s.settingsMutex.RUnlock()
+
+	s.documentsMutex.Lock()
+	defer s.documentsMutex.Unlock()
+
+	current, ok := s.Documents[data.URI]
+
+	if !ok || current.Version != data.Version || current.Text != data.Text {
+		return data, false
+	}
+
+	s.Documents[data.URI] = data
+
+	return data, true
+}
+
+// resultID identifies the diagnostics of a document by its version, its
+// content and the generation of the settings used to check it.
+func (s *State) resultID(data documentData) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(data.Text))
+
+	return fmt.Sprintf("%d-%d-%016x", data.Version, s.generation, hash.Sum64())
+}
+
 func (s *State) CodeAction(request lsp.CodeActionRequest, uri string, logger *log.Logger) lsp.CodeActionResponse {
+	document, ok := s.document(uri)
+
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
 	params := request.Params
 	rng := params.Range
 
@@ -204,7 +642,6 @@ func (s *State) CodeAction(request lsp.CodeActionRequest, uri string, logger *lo
 	}
 
 	actions := []lsp.CodeAction{}
-	document, ok := s.Documents[uri]
 
 	if !ok {
 		return lsp.CodeActionResponse{
@@ -213,89 +650,103 @@ func (s *State) CodeAction(request lsp.CodeActionRequest, uri string, logger *lo
 		}
 	}
 
-	text := document.Text
-	lines := strings.Split(text, "\n")
-	line := lines[rng.Start.Line]
-	full_range := growRange(line, rng)
+	line, ok := lineAt(document.Text, rng.Start.Line)
 
-	relevant_text := line[full_range.Start.Character : full_range.End.Character+1]
+	if !ok {
+		return lsp.CodeActionResponse{
+			Response: lsp.CreateResponse(request.ID),
+			Result:   []lsp.CodeAction{},
+		}
+	}
+
+	if document.isExcluded(s, logger) {
+		return s.codeActionResponse(request, actions)
+	}
 
-	words := splitIntoWords(rng.Start.Line, full_range.Start.Character, relevant_text)
+	// Actions are only offered for the words which are checked
+	settings, directives := s.documentSettings(document, logger)
+	checked, ok := s.checkedLine(document, settings, directives, rng.Start.Line, logger)
+
+	if !ok {
+		return s.codeActionResponse(request, actions)
+	}
+
+	runes := []rune(line)
+	start, end := growRange(runes,
+		runeColumn(line, rng.Start.Character, s.PositionEncoding),
+		runeColumn(line, rng.End.Character, s.PositionEncoding))
+
+	relevant_text := string([]rune(checked)[start:end])
+
+	words := splitIntoWords(rng.Start.Line, start, relevant_text)
+	unknown := []lsp.Diagnostic{}
+	ignored := []string{}
+	found := false
 
 	for _, word := range words {
-		if s.Spellchecker.IsCorrect(strings.ToLower(word.Text)) {
+		if match, ok := s.matchWord(word.Text, settings); ok {
+			if dictionaries := s.removableFrom(uri, match); len(dictionaries) > 0 {
+				actions = append(actions, removeFromDictionaryAction(dictionaries, uri, match.Word))
+			}
+
 			continue
 		}
 
+		diagnostics := diagnosticsAt(params.Context.Diagnostics, wordRange(line, word, s.PositionEncoding))
+
 		has_trailing_s := strings.HasSuffix(word.Text, "s")
 		has_trailing_es := strings.HasSuffix(word.Text, "es")
 
-		if has_trailing_s {
-			if s.Spellchecker.IsCorrect(strings.ToLower(word.Text[:len(word.Text)-1])) {
+		forbidden := s.isForbidden(word.Text, settings)
+
+		if has_trailing_s && !forbidden {
+			if s.dictionaries(settings).Contains(strings.ToLower(word.Text[:len(word.Text)-1])) {
 				continue
 			}
 		}
 
-		if has_trailing_es {
-			if s.Spellchecker.IsCorrect(strings.ToLower(word.Text[:len(word.Text)-1])) {
+		if has_trailing_es && !forbidden {
+			if s.dictionaries(settings).Contains(strings.ToLower(word.Text[:len(word.Text)-1])) {
 				continue
 			}
 		}
 
-		suggestions, suggestions_err := s.Spellchecker.Suggest(word.Text, s.MaxSuggestions)
+		unknown = append(unknown, diagnostics...)
+		ignored = append(ignored, word.Text)
+		found = true
+		suggestions := s.suggest(word.Text, settings)
 
-		if s.DictionaryPath != "" {
-			if has_trailing_s && s.AllowImplicitPlurals {
-				actions = append(actions, lsp.CodeAction{
-					Title: fmt.Sprintf("Add '%s' to dictionary", word.Text),
-					Command: &lsp.Command{
-						Title:   "Add to dictionary",
-						Command: "proof.add_to_dictionary",
-						Arguments: []string{
-							uri,
-							word.Text[:len(word.Text)-1],
-						},
-					},
-				})
-			} else if has_trailing_es && s.AllowImplicitPlurals {
-				actions = append(actions, lsp.CodeAction{
-					Title: fmt.Sprintf("Add '%s' to dictionary", word.Text),
-					Command: &lsp.Command{
-						Title:   "Add to dictionary",
-						Command: "proof.add_to_dictionary",
-						Arguments: []string{
-							uri,
-							word.Text[:len(word.Text)-2],
-						},
-					},
-				})
-			} else {
-				actions = append(actions, lsp.CodeAction{
-					Title: fmt.Sprintf("Add '%s' to dictionary", word.Text),
-					Command: &lsp.Command{
-						Title:   "Add to dictionary",
-						Command: "proof.add_to_dictionary",
-						Arguments: []string{
-							uri,
-							word.Text,
-						},
-					},
-				})
-			}
+		// Implicit plurals are added without the trailing 's'
+		added := word.Text
+
+		if has_trailing_s && settings.AllowImplicitPlurals {
+			added = word.Text[:len(word.Text)-1]
+		}
+
+		// Forbidden words stay forbidden when they are added
+		if _, ok := s.projectDictionaryFor(uri); ok && !forbidden {
+			actions = append(actions, addToDictionaryAction("project", "proof.add_to_project_dictionary", uri, word.Text, added, diagnostics))
+		}
+
+		if s.DictionaryPath != "" && !forbidden {
+			actions = append(actions, addToDictionaryAction("user", "proof.add_to_dictionary", uri, word.Text, added, diagnostics))
 		}
 
-		if suggestions_err != nil {
+		if len(suggestions) == 0 {
 			break
 		}
 
-		for _, suggestion := range suggestions {
+		for i, suggestion := range suggestions {
 			action := lsp.CodeAction{
-				Title: fmt.Sprintf("Replace with '%s'", suggestion),
+				Title:       fmt.Sprintf("Replace with '%s'", suggestion),
+				Kind:        lsp.QuickFix,
+				Diagnostics: diagnostics,
+				IsPreferred: i == 0 && s.Client.SupportsPreferredCodeActions(),
 				Edit: &lsp.WorkspaceEdit{
 					Changes: map[string][]lsp.TextEdit{
 						uri: {
 							{
-								Range:   lineRange(word.Row, word.Start, word.End),
+								Range:   wordRange(line, word, s.PositionEncoding),
 								NewText: suggestion,
 							},
 						},
@@ -308,12 +759,77 @@ func (s *State) CodeAction(request lsp.CodeActionRequest, uri string, logger *lo
 
 	}
 
-	response := lsp.CodeActionResponse{
-		Response: lsp.CreateResponse(request.ID),
-		Result:   actions,
+	if !found {
+		return s.codeActionResponse(request, actions)
 	}
 
-	return response
+	// A line inserted into a string literal spanning several lines would
+	// change the literal, so the words are declared at the end instead
+	e := extraction{state: s, uri: uri, settings: settings, logger: logger}
+	directive, ok := ignoreLineDirective(document.LanguageID, line)
+
+	if ok && !e.literals(document.LanguageID, document.Text)(lineOffset(document.Text, rng.Start.Line)) {
+		position := lsp.Position{Line: rng.Start.Line, Character: 0}
+
+		actions = append(actions, lsp.CodeAction{
+			Title:       "Ignore on this line",
+			Kind:        lsp.QuickFix,
+			Diagnostics: unknown,
+			Edit: &lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{
+					uri: {{Range: lsp.Range{Start: position, End: position}, NewText: directive}},
+				},
+			},
+		})
+
+		return s.codeActionResponse(request, actions)
+	}
+
+	for _, word := range ignored {
+		if edit, ok := wordsDirective(document.LanguageID, document.Text, word, s.PositionEncoding); ok {
+			actions = append(actions, lsp.CodeAction{
+				Title:       fmt.Sprintf("Ignore '%s' in this document", word),
+				Kind:        lsp.QuickFix,
+				Diagnostics: unknown,
+				Edit: &lsp.WorkspaceEdit{
+					Changes: map[string][]lsp.TextEdit{uri: {edit}},
+				},
+			})
+		}
+	}
+
+	return s.codeActionResponse(request, actions)
+}
+
+func addToDictionaryAction(dictionary string, command string, uri string, word string, added string, diagnostics []lsp.Diagnostic) lsp.CodeAction {
+	return lsp.CodeAction{
+		Title:       fmt.Sprintf("Add '%s' to %s dictionary", word, dictionary),
+		Kind:        lsp.QuickFix,
+		Diagnostics: diagnostics,
+		Command: &lsp.Command{
+			Title:     fmt.Sprintf("Add to %s dictionary", dictionary),
+			Command:   command,
+			Arguments: []string{uri, added},
+		},
+	}
+}
+
+func removeFromDictionaryAction(dictionaries []string, uri string, word string) lsp.CodeAction {
+	title := fmt.Sprintf("Remove '%s' from %s dictionary", word, dictionaries[0])
+
+	if len(dictionaries) > 1 {
+		title = fmt.Sprintf("Remove '%s' from %s dictionaries", word, strings.Join(dictionaries, " and "))
+	}
+
+	return lsp.CodeAction{
+		Title: title,
+		Kind:  lsp.QuickFix,
+		Command: &lsp.Command{
+			Title:     "Remove from dictionary",
+			Command:   "proof.remove_from_dictionary",
+			Arguments: []string{uri, word},
+		},
+	}
 }
 
 func lineRange(row, start, end int) lsp.Range {
@@ -323,23 +839,43 @@ func lineRange(row, start, end int) lsp.Range {
 	}
 }
 
-func getDiagnosticsForFile(uri string, s *State, logger *log.Logger) []lsp.Diagnostic {
-	document := s.Documents[uri]
-	return getDiagnostics(document, s, logger)
-}
-
 func getDiagnostics(document documentData, s *State, logger *log.Logger) []lsp.Diagnostic {
-	text := document.Text
+	settings, directives := s.documentSettings(document, logger)
+
+	if directives.disabledFile {
+		return []lsp.Diagnostic{}
+	}
+
+	lines := strings.Split(document.Text, "\n")
+	checked := slices.Clone(lines)
+
+	if text, ok := s.checkedText(document, settings, logger); ok {
+		checked = strings.Split(text, "\n")
+	}
+
+	for row := range directives.ignoredLines {
+		if row < len(checked) {
+			checked[row] = ""
+		}
+	}
 
+	return getLinesDiagnostics(0, lines, checked, settings, s, logger)
+}
+
+// getLinesDiagnostics checks consecutive lines of a document starting at the
+// line firstRow with the settings of the document. Only the words in the
+// checked lines are checked, which are the lines with everything that
+// shouldn't be checked blanked out.
+func getLinesDiagnostics(firstRow int, lines []string, checked []string, settings Settings, s *State, logger *log.Logger) []lsp.Diagnostic {
 	diagnostics := []lsp.Diagnostic{}
-	severity := lsp.Hint
+	severity := settings.Severity
 
-	for row, line := range strings.Split(text, "\n") {
-		if strings.Trim(line, "\t \r\n") == "" {
+	for i, line := range lines {
+		if strings.Trim(checked[i], "\t \r\n") == "" {
 			continue
 		}
 
-		line_diagnostics := checkSplitWordsWithStruct(row, line, s, logger, severity)
+		line_diagnostics := checkSplitWordsWithStruct(firstRow+i, line, checked[i], settings, s, logger, severity)
 
 		diagnostics = append(diagnostics, line_diagnostics...)
 	}
@@ -347,36 +883,18 @@ func getDiagnostics(document documentData, s *State, logger *log.Logger) []lsp.D
 	return diagnostics
 }
 
-func checkSplitWordsWithStruct(row int, line string, s *State, _ *log.Logger, severity lsp.DiagnosticSeverity) []lsp.Diagnostic {
+func checkSplitWordsWithStruct(row int, line string, checked string, settings Settings, s *State, _ *log.Logger, severity lsp.DiagnosticSeverity) []lsp.Diagnostic {
 	diagnostics := []lsp.Diagnostic{}
 
-	words := splitIntoWords(row, 0, line)
+	words := splitIntoWords(row, 0, skipTokens(checked, settings))
 
 	for _, word := range words {
-		word_lower := strings.ToLower(word.Text)
-
-		if s.Spellchecker.IsCorrect(word_lower) {
+		if _, ok := s.matchWord(word.Text, settings); ok {
 			continue
 		}
 
-		if s.AllowImplicitPlurals && strings.HasSuffix(word_lower, "s") {
-			word_lower = word_lower[:len(word_lower)-1]
-
-			if s.Spellchecker.IsCorrect(word_lower) {
-				continue
-			}
-		}
-
-		if s.AllowImplicitPlurals && strings.HasSuffix(word_lower, "es") {
-			word_lower = word_lower[:len(word_lower)-1]
-
-			if s.Spellchecker.IsCorrect(word_lower) {
-				continue
-			}
-		}
-
 		diagnostics = append(diagnostics, lsp.Diagnostic{
-			Range:    lineRange(word.Row, word.Start, word.End),
+			Range:    wordRange(line, word, s.PositionEncoding),
 			Severity: &severity,
 			Source:   "proof",
 			Message:  fmt.Sprintf("Typo in word: %s", word.Text),
@@ -459,33 +977,28 @@ func splitIntoWords(row int, offset_from_start int, line string) []Word {
 	return words
 }
 
-func growRange(text string, rng lsp.Range) lsp.Range {
-	start := rng.Start
-	end := rng.End
-
-	for start.Character > 0 {
-		if !unicode.IsLetter(rune(text[start.Character-1])) {
-			break
-		}
+// growRange grows the rune columns start and end (exclusive) of a line to
+// include the whole words they touch.
+func growRange(line []rune, start int, end int) (int, int) {
+	start = min(max(start, 0), len(line))
+	end = min(max(end, start), len(line))
 
-		start.Character--
+	for start > 0 && unicode.IsLetter(line[start-1]) {
+		start--
 	}
 
-	for end.Character < len(text) {
-		if end.Character+1 >= len(text) || !unicode.IsLetter(rune(text[end.Character+1])) {
-			break
-		}
-
-		end.Character++
+	for end < len(line) && unicode.IsLetter(line[end]) {
+		end++
 	}
 
-	return lsp.Range{Start: start, End: end}
+	return start, end
 }
 
 func createDocumentData(document lsp.TextDocumentItem) documentData {
 	uri := document.URI
 	text := document.Text
 	languageID := document.LanguageID
+	version := document.Version
 
 	last_index := strings.LastIndex(uri, ".")
 
@@ -495,6 +1008,7 @@ func createDocumentData(document lsp.TextDocumentItem) documentData {
 			Text:       text,
 			LanguageID: languageID,
 			Extension:  "",
+			Version:    version,
 		}
 	}
 
@@ -505,49 +1019,53 @@ func createDocumentData(document lsp.TextDocumentItem) documentData {
 		Text:       text,
 		LanguageID: languageID,
 		Extension:  extension,
+		Version:    version,
 	}
 }
 
-func updateDocumentData(document documentData, change string) documentData {
+func updateDocumentData(document documentData, change string, version int) documentData {
 	return documentData{
 		URI:        document.URI,
 		Text:       change,
 		LanguageID: document.LanguageID,
 		Extension:  document.Extension,
+		Version:    version,
 	}
 }
 
-func ensureDir(filePath string) error {
-	dir := filepath.Dir(filePath)
-	return os.MkdirAll(dir, 0755)
-}
-
-func sliceEqual[T comparable](a, b []T) bool {
-	if len(a) != len(b) {
-		return false
+// appendWord adds a word to the end of a dictionary file, creating the file
+// and its directory if they don't exist.
+func appendWord(path string, word string) error {
+	if err := ensureDir(path); err != nil {
+		return err
 	}
 
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
 	}
 
-	return true
+	defer file.Close()
+
+	_, err = file.WriteString(word + "\n")
+
+	return err
 }
 
-func (data documentData) isExcluded(s *State, logger *log.Logger) bool {
-	for _, pattern := range s.ExcludedFilePatterns {
-		re, err := regexp.Compile(pattern)
+func ensureDir(filePath string) error {
+	dir := filepath.Dir(filePath)
+	return os.MkdirAll(dir, 0755)
+}
 
-		if err != nil {
-			logger.Panicf("Failed to compile regex pattern: %s, %s", pattern, err)
-		}
+func (data documentData) isExcluded(s *State, _ *log.Logger) bool {
+	settings := s.settingsFor(data.URI)
 
+	for _, re := range settings.excludedFilePatterns {
 		if re.MatchString(data.URI) {
 			return true
 		}
 	}
 
-	return slices.Contains(s.ExcludedFileTypes, data.LanguageID)
+	return slices.Contains(settings.ExcludedFileTypes, data.LanguageID)
 }
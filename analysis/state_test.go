@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"testing"
+)
+
+func TestDiagnosticResultIDs(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello")
+	uri := "file:///test.txt"
+
+	state.OpenDocument(lsp.TextDocumentItem{URI: uri, LanguageID: "text", Version: 1, Text: "hello wrold"}, logger)
+
+	kind, diagnostics, first := state.Diagnostic(uri, "", logger)
+
+	if kind != lsp.Full || len(diagnostics) != 1 || first == "" {
+		t.Fatalf("Expected a full report with a result id, got %s %v %q", kind, diagnostics, first)
+	}
+
+	// The client already has the diagnostics of this result id
+	kind, diagnostics, resultID := state.Diagnostic(uri, first, logger)
+
+	if kind != lsp.Unchanged || diagnostics != nil || resultID != first {
+		t.Fatalf("Expected an unchanged report for %q, got %s %v %q", first, kind, diagnostics, resultID)
+	}
+
+	// Edits change the result id
+	state.UpdateDocument(
+		lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		[]lsp.TextDocumentContentChangeEvent{{Text: "hello world"}},
+		logger)
+
+	kind, diagnostics, edited := state.Diagnostic(uri, first, logger)
+
+	if kind != lsp.Full || len(diagnostics) != 1 || edited == first {
+		t.Fatalf("Expected a full report with a new result id after an edit, got %s %v %q", kind, diagnostics, edited)
+	}
+
+	// Settings changes affect the diagnostics of unchanged documents too
+	state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{IgnoredWords: []string{"world"}}}, logger)
+
+	kind, diagnostics, configured := state.Diagnostic(uri, edited, logger)
+
+	if kind != lsp.Full || len(diagnostics) != 0 || configured == edited {
+		t.Fatalf("Expected a full report with a new result id after a settings change, got %s %v %q", kind, diagnostics, configured)
+	}
+
+	if kind, _, _ := state.Diagnostic(uri, configured, logger); kind != lsp.Unchanged {
+		t.Errorf("Expected an unchanged report for the new result id, got %s", kind)
+	}
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"proof/lsp"
+	"strings"
+	"unicode/utf8"
+)
+
+// applyChange replaces the text in rng with newText. It returns the new text
+// together with the first and last line in the new text touched by the
+// change and the number of lines everything after the change moved by.
+func applyChange(text string, rng lsp.Range, newText string, encoding lsp.PositionEncodingKind) (string, int, int, int) {
+	start := offsetAt(text, rng.Start, encoding)
+	end := offsetAt(text, rng.End, encoding)
+
+	if end < start {
+		start, end = end, start
+		rng.Start, rng.End = rng.End, rng.Start
+	}
+
+	insertedLines := strings.Count(newText, "\n")
+	removedLines := strings.Count(text[start:end], "\n")
+
+	text = text[:start] + newText + text[end:]
+
+	return text, rng.Start.Line, rng.Start.Line + insertedLines, insertedLines - removedLines
+}
+
+// offsetAt converts a position in the encoding into a byte offset into text.
+// Positions past the end of a line or the end of the text are clamped.
+func offsetAt(text string, position lsp.Position, encoding lsp.PositionEncodingKind) int {
+	offset := lineOffset(text, position.Line)
+
+	for units := 0; units < position.Character && offset < len(text); {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+
+		if r == '\n' {
+			break
+		}
+
+		units += codeUnits(r, encoding)
+		offset += size
+	}
+
+	return offset
+}
+
+// lineOffset returns the byte offset of the start of a line in text.
+func lineOffset(text string, line int) int {
+	offset := 0
+
+	for ; line > 0; line-- {
+		index := strings.IndexByte(text[offset:], '\n')
+
+		if index == -1 {
+			return len(text)
+		}
+
+		offset += index + 1
+	}
+
+	return offset
+}
+
+// linesBetween returns the lines first through last, inclusive, of text.
+func linesBetween(text string, first int, last int) []string {
+	start := lineOffset(text, first)
+	end := start
+
+	for line := first; line <= last && end < len(text); line++ {
+		index := strings.IndexByte(text[end:], '\n')
+
+		if index == -1 {
+			end = len(text)
+			break
+		}
+
+		end += index + 1
+	}
+
+	return strings.Split(strings.TrimSuffix(text[start:end], "\n"), "\n")
+}
+
+// shiftDiagnostics drops the diagnostics on the lines first through last and
+// moves the diagnostics after them by delta lines.
+func shiftDiagnostics(diagnostics []lsp.Diagnostic, first int, last int, delta int) ([]lsp.Diagnostic, []lsp.Diagnostic) {
+	before := []lsp.Diagnostic{}
+	after := []lsp.Diagnostic{}
+
+	for _, diagnostic := range diagnostics {
+		switch {
+		case diagnostic.Range.End.Line < first:
+			before = append(before, diagnostic)
+
+		case diagnostic.Range.Start.Line > last:
+			diagnostic.Range.Start.Line += delta
+			diagnostic.Range.End.Line += delta
+			after = append(after, diagnostic)
+		}
+	}
+
+	return before, after
+}
@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"io"
+	"log"
+	"proof/lsp"
+	"strings"
+	"testing"
+)
+
+func newTestState(t *testing.T, words ...string) *State {
+	state, err := NewState(words)
+
+	if err != nil {
+		t.Fatalf("Error creating spellchecker: %s", err)
+	}
+
+	return state
+}
+
+func TestIncrementalChangesMatchFullCheck(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello", "world", "one", "two", "three")
+	uri := "file:///test.txt"
+
+	state.OpenDocument(lsp.TextDocumentItem{
+		URI:        uri,
+		LanguageID: "text",
+		Version:    1,
+		Text:       "hello wrold\none twoo\nthree\nfoo bar",
+	}, logger)
+
+	state.CheckDocument(uri, logger)
+
+	changes := []lsp.TextDocumentContentChangeEvent{
+		// Insert a line with a typo in the middle of the document
+		{Range: &lsp.Range{Start: lsp.Position{Line: 1, Character: 8}, End: lsp.Position{Line: 1, Character: 8}}, Text: "\nthre hello"},
+		// Fix the typo on the first line
+		{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 6}, End: lsp.Position{Line: 0, Character: 11}}, Text: "world"},
+		// Join the last two lines
+		{Range: &lsp.Range{Start: lsp.Position{Line: 3, Character: 5}, End: lsp.Position{Line: 4, Character: 0}}, Text: " "},
+	}
+
+	state.UpdateDocument(
+		lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2},
+		changes,
+		logger)
+
+	expectedText := "hello world\none twoo\nthre hello\nthree foo bar"
+
+	if text := state.Documents[uri].Text; text != expectedText {
+		t.Fatalf("Expected text %q, got %q", expectedText, text)
+	}
+
+	diagnostics := state.Documents[uri].Diagnostics
+	expected := getDiagnostics(state.Documents[uri], state, logger)
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %d", len(expected), len(diagnostics))
+	}
+
+	for i := range expected {
+		if diagnostics[i].Range != expected[i].Range || diagnostics[i].Message != expected[i].Message {
+			t.Errorf("Expected %v, got %v", expected[i], diagnostics[i])
+		}
+	}
+
+	words := []string{}
+
+	for _, diagnostic := range diagnostics {
+		words = append(words, strings.TrimPrefix(diagnostic.Message, "Typo in word: "))
+	}
+
+	if strings.Join(words, ",") != "twoo,thre,foo,bar" {
+		t.Errorf("Unexpected typos: %v", words)
+	}
+}
+
+func TestMarkdownChangesOnlyCheckTheirBlock(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	state := newTestState(t, "hello", "world", "one", "two", "three")
+	uri := "file:///test.md"
+	identifier := lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri}, Version: 2}
+
+	state.OpenDocument(lsp.TextDocumentItem{
+		URI:        uri,
+		LanguageID: "markdown",
+		Version:    1,
+		Text:       "# hello wrold\n\none twoo\nthree\n\n```go\nfoo := bar\n```\n\nthree thre",
+	}, logger)
+
+	state.CheckDocument(uri, logger)
+
+	// Other blocks keep their diagnostics, which would be dropped if they
+	// were checked again
+	document := state.Documents[uri]
+	marker := lsp.Diagnostic{Range: lsp.Range{Start: lsp.Position{Line: 9}, End: lsp.Position{Line: 9, Character: 5}}, Message: "marker"}
+	document.Diagnostics = append(document.Diagnostics, marker)
+	state.Documents[uri] = document
+
+	// Fix the typo in the paragraph and add a line to it
+	state.UpdateDocument(identifier, []lsp.TextDocumentContentChangeEvent{
+		{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 4}, End: lsp.Position{Line: 2, Character: 8}}, Text: "two\nhello wrld"},
+	}, logger)
+
+	document = state.Documents[uri]
+
+	if document.Stale {
+		t.Fatal("Expected a change to a paragraph not to check the whole document again")
+	}
+
+	words := []string{}
+
+	for _, diagnostic := range document.Diagnostics {
+		words = append(words, strings.TrimPrefix(diagnostic.Message, "Typo in word: "))
+	}
+
+	if strings.Join(words, ",") != "wrold,wrld,thre,marker" {
+		t.Errorf("Unexpected typos: %v", words)
+	}
+
+	if line := document.Diagnostics[len(document.Diagnostics)-1].Range.Start.Line; line != 10 {
+		t.Errorf("Expected the diagnostics after the change to move to line 10, got %d", line)
+	}
+
+	// Removing the end of the code block changes the regions of the following
+	// lines
+	state.UpdateDocument(identifier, []lsp.TextDocumentContentChangeEvent{
+		{Range: &lsp.Range{Start: lsp.Position{Line: 8, Character: 0}, End: lsp.Position{Line: 8, Character: 3}}, Text: ""},
+	}, logger)
+
+	if !state.Documents[uri].Stale {
+		t.Error("Expected a change to a fence to check the whole document again")
+	}
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// The names of the detectors for tokens which aren't words, as used in the
+// skippedTokens setting.
+const (
+	tokenURLs      = "urls"
+	tokenEmails    = "emails"
+	tokenPaths     = "paths"
+	tokenUUIDs     = "uuids"
+	tokenHexColors = "hexColors"
+	tokenHashes    = "hashes"
+	tokenSecrets   = "secrets"
+)
+
+// tokenDetector finds tokens in a line which are skipped when splitting it
+// into words. Only the submatch group is skipped if the pattern has one, and
+// only matches accepted by the filter if there is one.
+type tokenDetector struct {
+	name    string
+	pattern *regexp.Regexp
+	filter  func(token string) bool
+}
+
+// tokenDetectors are run on every checked line in this order, so tokens
+// like email addresses in URLs are skipped as a whole.
+var tokenDetectors = []tokenDetector{
+	{
+		name:    tokenURLs,
+		pattern: regexp.MustCompile(`\b(?:[A-Za-z][A-Za-z0-9+.-]*://|www\.)[^\s<>"'` + "`" + `)\]]+`),
+	},
+	{
+		name:    tokenEmails,
+		pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	{
+		name:    tokenUUIDs,
+		pattern: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+	},
+	{
+		name: tokenPaths,
+		pattern: regexp.MustCompile(`(?:^|[\s"'(=:,\[])(` +
+			`(?:~|\.{1,2})?/[\w.@+-]+(?:/[\w.@+-]*)*|` +
+			`[\w.-]+(?:/[\w.@+-]+)+\.[A-Za-z0-9]+|` +
+			`[\w.-]+(?:/[\w.@+-]+){2,}/?|` +
+			`[A-Za-z]:\\[^\s"']+)`),
+	},
+	{
+		name:    tokenHexColors,
+		pattern: regexp.MustCompile(`#(?:[0-9a-fA-F]{8}|[0-9a-fA-F]{6}|[0-9a-fA-F]{3,4})\b`),
+	},
+	{
+		name:    tokenHashes,
+		pattern: regexp.MustCompile(`\b(?:0[xX][0-9a-fA-F]+|[0-9a-fA-F]{7,64})\b`),
+		filter:  hasDigit,
+	},
+	{
+		name:    tokenSecrets,
+		pattern: regexp.MustCompile(`[A-Za-z0-9+/_-]{20,}={0,2}`),
+		filter:  isSecret,
+	},
+}
+
+// skipTokens replaces the tokens found by the detectors enabled in the
+// settings and the text matched by the ignoredPatterns with spaces. The
+// number of runes in the line is kept.
+func skipTokens(line string, settings Settings) string {
+	skipped := []span{}
+
+	for _, detector := range tokenDetectors {
+		if !settings.SkippedTokens[detector.name] {
+			continue
+		}
+
+		for _, match := range detector.pattern.FindAllStringSubmatchIndex(line, -1) {
+			start, end := match[0], match[1]
+
+			if len(match) > 2 {
+				start, end = match[2], match[3]
+			}
+
+			if detector.filter != nil && !detector.filter(line[start:end]) {
+				continue
+			}
+
+			skipped = append(skipped, span{start, end})
+		}
+	}
+
+	for _, pattern := range settings.ignoredPatterns {
+		for _, match := range pattern.FindAllStringIndex(line, -1) {
+			skipped = append(skipped, span{match[0], match[1]})
+		}
+	}
+
+	if len(skipped) == 0 {
+		return line
+	}
+
+	return maskText(line, complement(line, skipped))
+}
+
+func hasDigit(token string) bool {
+	return strings.IndexFunc(token, unicode.IsDigit) != -1
+}
+
+// isSecret reports if a token looks like a key, a token or base64 encoded
+// data rather than a long identifier. The characters of such data switch
+// between upper case, lower case and digits far more often than those of
+// identifiers and are about as random.
+func isSecret(token string) bool {
+	counts := map[rune]int{}
+	switches, previous := 0, 0
+
+	for _, r := range token {
+		counts[r]++
+		class := 0
+
+		switch {
+		case unicode.IsUpper(r):
+			class = 1
+		case unicode.IsLower(r):
+			class = 2
+		case unicode.IsDigit(r):
+			class = 3
+		default:
+			continue
+		}
+
+		if previous != 0 && class != previous {
+			switches++
+		}
+
+		previous = class
+	}
+
+	if float64(switches) < 0.4*float64(len(token)) {
+		return false
+	}
+
+	entropy := 0.0
+
+	for _, count := range counts {
+		p := float64(count) / float64(len(token))
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy >= 3.5
+}
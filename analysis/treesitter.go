@@ -0,0 +1,275 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_go "github.com/tree-sitter/tree-sitter-go/bindings/go"
+	tree_sitter_html "github.com/tree-sitter/tree-sitter-html/bindings/go"
+	tree_sitter_java "github.com/tree-sitter/tree-sitter-java/bindings/go"
+	tree_sitter_json "github.com/tree-sitter/tree-sitter-json/bindings/go"
+	tree_sitter_python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	tree_sitter_rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
+)
+
+// grammar is a tree-sitter grammar together with the query selecting the
+// regions to check when the user didn't write their own. Only the content of
+// strings is captured where strings have prefixes or embed code.
+type grammar struct {
+	language     func() unsafe.Pointer
+	defaultQuery string
+}
+
+// grammars maps language identifiers to the tree-sitter grammars shipped
+// with proof.
+var grammars = map[string]grammar{
+	"go": {
+		language: tree_sitter_go.Language,
+		defaultQuery: `
+			(comment) @comment
+			[(interpreted_string_literal_content) (raw_string_literal_content)] @string
+			[(identifier) (field_identifier) (type_identifier) (package_identifier)] @identifier`,
+	},
+	"python": {
+		language: tree_sitter_python.Language,
+		defaultQuery: `
+			(comment) @comment
+			(string_content) @string
+			(identifier) @identifier`,
+	},
+	"java": {
+		language: tree_sitter_java.Language,
+		defaultQuery: `
+			[(line_comment) (block_comment)] @comment
+			[(string_fragment) (multiline_string_fragment)] @string
+			[(identifier) (type_identifier)] @identifier`,
+	},
+	"json": {
+		language: tree_sitter_json.Language,
+		defaultQuery: `
+			(comment) @comment
+			(string) @string`,
+	},
+	"rust": {
+		language: tree_sitter_rust.Language,
+		defaultQuery: `
+			[(line_comment) (block_comment)] @comment
+			(string_content) @string
+			[(identifier) (field_identifier) (type_identifier)] @identifier`,
+	},
+	"html": {
+		language: tree_sitter_html.Language,
+		defaultQuery: `
+			(comment) @comment
+			(text) @text
+			(attribute
+				(attribute_name) @_name
+				[(attribute_value) (quoted_attribute_value)] @string
+				(#any-of? @_name "alt" "title" "placeholder" "label" "aria-label" "aria-description"))`,
+	},
+}
+
+// The name of the files containing user defined queries. They are looked
+// up in 'queries/<languageId>/proof.scm' below the '.proof' directory of
+// the workspace folder and below the proof directory of the user config.
+const queryFileName = "proof.scm"
+
+// captureKinds maps the names of captures to the kind of region they
+// select. Captures with other names are always checked, except for the ones
+// starting with '_' which are only used in predicates.
+var captureKinds = map[string]regionKind{
+	"comment":    regionComment,
+	"string":     regionString,
+	"identifier": regionIdentifier,
+	"text":       regionText,
+}
+
+// queryDirs returns the directories proof.scm files are looked up in for a
+// document, in the order of their precedence.
+func (s *State) queryDirs(uri string) []string {
+	dirs := []string{}
+
+	if folder, ok := s.workspaceFolder(uri); ok {
+		dirs = append(dirs, filepath.Join(folder, ".proof", "queries"))
+	}
+
+	if config, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(config, "proof", "queries"))
+	}
+
+	return dirs
+}
+
+// userQuery returns the path of the proof.scm file for a language if there
+// is one.
+func userQuery(dirs []string, languageID string) (string, bool) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, languageID, queryFileName)
+
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+type compiledQuery struct {
+	query    *tree_sitter.Query
+	modified time.Time
+	err      error
+
+	// The number of extractions using the query and if it was replaced by
+	// a query compiled again. It is closed once both are the case.
+	users    int
+	replaced bool
+}
+
+var (
+	// Guards queries and the users of the compiled queries. It is only held
+	// while looking up queries, they are used without it.
+	queriesMutex sync.Mutex
+
+	// Compiled queries keyed by the language and the path of the query
+	// file, which is empty for the default queries.
+	queries = make(map[[2]string]*compiledQuery)
+)
+
+// acquireQuery compiles the query of a language once and returns it. Query
+// files are compiled again when they were modified. The query has to be
+// released when it is no longer used.
+func acquireQuery(languageID string, language *tree_sitter.Language, path string) (*compiledQuery, error) {
+	queriesMutex.Lock()
+	defer queriesMutex.Unlock()
+
+	key := [2]string{languageID, path}
+	source := grammars[languageID].defaultQuery
+	modified := time.Time{}
+
+	if path != "" {
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		modified = info.ModTime()
+	}
+
+	compiled, ok := queries[key]
+
+	if !ok || !compiled.modified.Equal(modified) {
+		if path != "" {
+			content, err := os.ReadFile(path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			source = string(content)
+		}
+
+		if ok {
+			compiled.replaced = true
+			compiled.closeUnused()
+		}
+
+		query, err := tree_sitter.NewQuery(language, source)
+		compiled = &compiledQuery{modified: modified}
+
+		if err != nil {
+			compiled.err = err
+		} else {
+			compiled.query = query
+		}
+
+		queries[key] = compiled
+	}
+
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+
+	compiled.users++
+
+	return compiled, nil
+}
+
+// release marks the query as no longer used by the caller.
+func (compiled *compiledQuery) release() {
+	queriesMutex.Lock()
+	defer queriesMutex.Unlock()
+
+	compiled.users--
+	compiled.closeUnused()
+}
+
+// closeUnused closes the query if it was replaced and isn't used anymore.
+// The caller must hold queriesMutex.
+func (compiled *compiledQuery) closeUnused() {
+	if compiled.replaced && compiled.users == 0 && compiled.query != nil {
+		compiled.query.Close()
+		compiled.query = nil
+	}
+}
+
+// extractTreeSitter parses the text with the grammar of the language and
+// returns the regions selected by the query at path, or by the default
+// query of the language if path is empty.
+func extractTreeSitter(languageID string, path string, text string) ([]region, error) {
+	language := tree_sitter.NewLanguage(grammars[languageID].language())
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+
+	if err := parser.SetLanguage(language); err != nil {
+		return nil, err
+	}
+
+	source := []byte(text)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	compiled, err := acquireQuery(languageID, language, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer compiled.release()
+
+	query := compiled.query
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	names := query.CaptureNames()
+	captures := cursor.Captures(query, tree.RootNode(), source)
+	regions := []region{}
+
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		capture := match.Captures[index]
+		name := names[capture.Index]
+
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		kind, ok := captureKinds[name]
+
+		if !ok {
+			kind = regionCustom
+		}
+
+		regions = append(regions, region{
+			Kind:  kind,
+			Start: int(capture.Node.StartByte()),
+			End:   int(capture.Node.EndByte()),
+		})
+	}
+
+	return regions, nil
+}
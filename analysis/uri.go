@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var windowsDrivePath = regexp.MustCompile(`^/[a-zA-Z]:`)
+
+// uriToPath converts a 'file://' URI into a path on the local file system.
+func uriToPath(uri string) (string, bool) {
+	parsed, err := url.Parse(uri)
+
+	if err != nil || parsed.Scheme != "file" {
+		return "", false
+	}
+
+	path := parsed.Path
+
+	if windowsDrivePath.MatchString(path) {
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path), true
+}
+
+// pathToURI converts a path on the local file system into a 'file://' URI.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	path = filepath.ToSlash(path)
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+)
+
+// The names of the layers of the dictionary stack and of the other sources
+// accepting words, as shown to users.
+const (
+	sourceBuiltin             = "built-in word list"
+	sourceDictionary          = "user dictionary"
+	sourceProjectDictionary   = "project dictionary"
+	sourceIgnoredWords        = "ignoredWords setting"
+	sourceIgnoredWordPatterns = "ignoredWordPatterns setting"
+	sourceDirective           = "proof:words directive"
+	sourceForbiddenWords      = "forbiddenWords setting"
+)
+
+type wordMatch struct {
+	// The word which was found in the dictionaries. This differs from the
+	// checked word when the word was accepted as an implicit plural.
+	Word   string
+	Source string
+	Plural bool
+}
+
+// dictionaries returns the dictionaries a document is checked with, the
+// ones taking precedence first.
+func (s *State) dictionaries(settings Settings) DictionaryStack {
+	return slices.Concat(
+		DictionaryStack{
+			{Name: sourceForbiddenWords, Dictionary: settings.forbiddenWords, Forbidden: true},
+			{Name: sourceIgnoredWords, Dictionary: settings.ignoredWords},
+			{Name: sourceDirective, Dictionary: settings.documentWords},
+			{Name: sourceProjectDictionary, Dictionary: settings.projectWords},
+			{Name: sourceDictionary, Dictionary: s.userDictionary},
+		},
+		s.Dictionaries,
+		DictionaryStack{
+			{Name: sourceBuiltin, Dictionary: SpellcheckerDictionary{s.checker()}},
+		},
+	)
+}
+
+// matchWord checks if a word is known by the dictionaries or ignored by the
+// settings of the document and reports which source accepted it.
+func (s *State) matchWord(word string, settings Settings) (wordMatch, bool) {
+	for _, pattern := range settings.ignoredWordPatterns {
+		if pattern.MatchString(word) {
+			return wordMatch{Word: word, Source: sourceIgnoredWordPatterns}, true
+		}
+	}
+
+	word_lower := strings.ToLower(word)
+	dictionaries := s.dictionaries(settings)
+	layer, ok := dictionaries.Lookup(word_lower)
+
+	if ok {
+		return wordMatch{Word: word_lower, Source: layer.Name}, true
+	}
+
+	if layer.Forbidden || !settings.AllowImplicitPlurals {
+		return wordMatch{}, false
+	}
+
+	for _, suffix := range []string{"s", "es"} {
+		if !strings.HasSuffix(word_lower, suffix) {
+			continue
+		}
+
+		singular := word_lower[:len(word_lower)-len(suffix)]
+
+		if singular == "" {
+			continue
+		}
+
+		if layer, ok := dictionaries.Lookup(singular); ok {
+			return wordMatch{Word: singular, Source: layer.Name, Plural: true}, true
+		}
+	}
+
+	return wordMatch{}, false
+}
+
+// removableFrom returns the user and project dictionaries a word accepted by
+// one of them has to be removed from to be unknown again. There are none if
+// the built-in word list knows the word as well.
+func (s *State) removableFrom(uri string, match wordMatch) []string {
+	if match.Source != sourceDictionary && match.Source != sourceProjectDictionary {
+		return nil
+	}
+
+	if _, ok := slices.BinarySearch(s.builtinWords, match.Word); ok {
+		return nil
+	}
+
+	dictionaries := []string{}
+
+	for _, name := range []string{"user", "project"} {
+		if dictionary, ok := s.dictionaryNamed(uri, name); ok && dictionary.Contains(match.Word) {
+			dictionaries = append(dictionaries, name)
+		}
+	}
+
+	return dictionaries
+}
+
+// isForbidden reports if a word is rejected by the forbiddenWords setting.
+func (s *State) isForbidden(word string, settings Settings) bool {
+	return settings.forbiddenWords.Contains(strings.ToLower(word))
+}
@@ -0,0 +1,274 @@
+package analysis
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/lsp"
+	"slices"
+)
+
+const workspaceDiagnosticBatchSize = 50
+
+// WorkspaceDiagnostic checks every file in the workspace folders. Reports are
+// handed to onBatch in batches together with the number of files checked so
+// far so that the caller can stream them to the client.
+func (s *State) WorkspaceDiagnostic(
+	previousResultIDs map[string]string,
+	onBatch func(reports []lsp.WorkspaceDocumentDiagnosticReport, checked int, total int),
+	logger *log.Logger) {
+
+	openDocuments := make(map[string]string)
+
+	for _, uri := range s.OpenDocuments() {
+		if path, ok := uriToPath(uri); ok {
+			openDocuments[filepath.Clean(path)] = uri
+		}
+	}
+
+	paths := []string{}
+
+	s.settingsMutex.RLock()
+	folders := slices.Clone(s.WorkspaceFolders)
+	s.settingsMutex.RUnlock()
+
+	for _, folder := range folders {
+		walkFiles(folder, s, logger, func(path string) {
+			paths = append(paths, path)
+		})
+	}
+
+	logger.Printf("Workspace diagnostic for %d files", len(paths))
+
+	batch := []lsp.WorkspaceDocumentDiagnosticReport{}
+
+	for i, path := range paths {
+		var report lsp.WorkspaceDocumentDiagnosticReport
+		var ok bool
+
+		if uri, open := openDocuments[path]; open {
+			report, ok = s.openDocumentReport(uri, previousResultIDs[uri], logger)
+		} else {
+			report, ok = s.fileReport(path, previousResultIDs, logger)
+		}
+
+		if ok {
+			batch = append(batch, report)
+		}
+
+		if len(batch) >= workspaceDiagnosticBatchSize || i == len(paths)-1 {
+			onBatch(batch, i+1, len(paths))
+			batch = []lsp.WorkspaceDocumentDiagnosticReport{}
+		}
+	}
+}
+
+func (s *State) openDocumentReport(uri string, previousResultID string, logger *log.Logger) (lsp.WorkspaceDocumentDiagnosticReport, bool) {
+	data, ok := s.document(uri)
+
+	if !ok {
+		return lsp.WorkspaceDocumentDiagnosticReport{}, false
+	}
+
+	kind, diagnostics, resultID := s.Diagnostic(uri, previousResultID, logger)
+	version := data.Version
+
+	return lsp.WorkspaceDocumentDiagnosticReport{
+		DocumentDiagnosticReport: lsp.NewDocumentDiagnosticReport(kind, diagnostics, resultID),
+		URI:                      uri,
+		Version:                  &version,
+	}, true
+}
+
+func (s *State) fileReport(path string, previousResultIDs map[string]string, logger *log.Logger) (lsp.WorkspaceDocumentDiagnosticReport, bool) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		logger.Printf("Failed to read file: %s", err)
+		return lsp.WorkspaceDocumentDiagnosticReport{}, false
+	}
+
+	if isBinary(content) {
+		return lsp.WorkspaceDocumentDiagnosticReport{}, false
+	}
+
+	uri := pathToURI(path)
+	data := createDocumentData(lsp.TextDocumentItem{
+		URI:        uri,
+		LanguageID: languageIDFromPath(path),
+		Text:       string(content),
+	})
+
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+
+	resultID := s.resultID(data)
+
+	if previousResultIDs[uri] == resultID {
+		return lsp.WorkspaceDocumentDiagnosticReport{
+			DocumentDiagnosticReport: lsp.NewDocumentDiagnosticReport(lsp.Unchanged, nil, resultID),
+			URI:                      uri,
+		}, true
+	}
+
+	diagnostics := s.clientDiagnostics(uri, getDiagnostics(data, s, logger))
+
+	return lsp.WorkspaceDocumentDiagnosticReport{
+		DocumentDiagnosticReport: lsp.NewDocumentDiagnosticReport(lsp.Full, diagnostics, resultID),
+		URI:                      uri,
+	}, true
+}
+
+// walkFiles calls fn for every file below root which is neither ignored by a
+// .gitignore file nor excluded by the settings. root may also be a file,
+// which is only skipped if the settings exclude it. The .gitignore files of
+// the directories above root are applied as well, up to the root of its git
+// repository or else of its workspace folder. The settings are only locked
+// while looking at a single file, so they can change during long walks and
+// the caller must not hold the lock.
+func walkFiles(root string, s *State, logger *log.Logger, fn func(path string)) {
+	root = filepath.Clean(root)
+
+	s.settingsMutex.RLock()
+	top := s.ignoreRoot(root)
+	s.settingsMutex.RUnlock()
+	ignore := gitignore{}
+	ignore.loadParents(top, root)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			logger.Printf("Failed to walk %s: %s", path, err)
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(top, path)
+
+		if relErr != nil || rel == "." {
+			rel = ""
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" || (path != root && ignore.ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+
+			ignore.load(path, rel)
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || (path != root && ignore.ignored(rel, false)) {
+			return nil
+		}
+
+		data := documentData{
+			URI:        pathToURI(path),
+			LanguageID: languageIDFromPath(path),
+		}
+
+		s.settingsMutex.RLock()
+		excluded := data.isExcluded(s, logger)
+		s.settingsMutex.RUnlock()
+
+		if excluded {
+			return nil
+		}
+
+		fn(path)
+		return nil
+	})
+
+	if err != nil {
+		logger.Printf("Failed to walk %s: %s", root, err)
+	}
+}
+
+// ignoreRoot returns the directory the .gitignore files applying to the
+// files below root start in.
+func (s *State) ignoreRoot(root string) string {
+	if dir, ok := repositoryRoot(root); ok {
+		return dir
+	}
+
+	if folder, ok := s.workspaceFolder(pathToURI(root)); ok {
+		return folder
+	}
+
+	return root
+}
+
+// repositoryRoot returns the nearest directory containing path which is the
+// root of a git repository. A .git file marks the root of a worktree or a
+// submodule.
+func repositoryRoot(path string) (string, bool) {
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		if filepath.Dir(dir) == dir {
+			return "", false
+		}
+	}
+}
+
+// FindWorkspaceRoot returns the directory used as the workspace folder when
+// proof runs in dir without a client: the root of the git repository
+// containing dir, or else the nearest directory with a config file or a
+// project dictionary, or else dir itself.
+func FindWorkspaceRoot(dir string) string {
+	dir = filepath.Clean(dir)
+
+	if root, ok := repositoryRoot(dir); ok {
+		return root
+	}
+
+	for candidate := dir; ; candidate = filepath.Dir(candidate) {
+		for _, name := range append(ConfigFileNames(), DefaultProjectDictionary) {
+			if _, err := os.Stat(filepath.Join(candidate, name)); err == nil {
+				return candidate
+			}
+		}
+
+		if filepath.Dir(candidate) == candidate {
+			return dir
+		}
+	}
+}
+
+// isBinary uses the same heuristic as git: content with a NUL byte in the
+// first few kilobytes is not text.
+func isBinary(content []byte) bool {
+	const sniffLength = 8000
+
+	if len(content) > sniffLength {
+		content = content[:sniffLength]
+	}
+
+	return bytes.IndexByte(content, 0) != -1
+}
+
+// workspaceFolder returns the innermost workspace folder containing a
+// document.
+func (s *State) workspaceFolder(uri string) (string, bool) {
+	path, ok := uriToPath(uri)
+
+	if !ok {
+		return "", false
+	}
+
+	folder := ""
+
+	for _, candidate := range s.WorkspaceFolders {
+		rel, err := filepath.Rel(candidate, path)
+
+		if err == nil && filepath.IsLocal(rel) && len(candidate) > len(folder) {
+			folder = candidate
+		}
+	}
+
+	return folder, folder != ""
+}
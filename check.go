@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proof/analysis"
+	"proof/lsp"
+	"strings"
+)
+
+const checkUsage = `USAGE: proof check [OPTIONS] PATH...
+Checks the files below the paths like an editor would and prints the typos.
+Files ignored by .gitignore or excluded by the settings are skipped. The git
+repository containing the current directory is the workspace folder, so its
+config files and project dictionary are used. Exits with 1 if there are typos.
+
+[OPTIONS]
+--dictionary PATH: A user dictionary to use as well`
+
+// runCheck runs the check subcommand and returns the exit code.
+func runCheck(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dictionary := flags.String("dictionary", "", "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		fmt.Fprintln(stderr, checkUsage)
+		return 2
+	}
+
+	dir, err := os.Getwd()
+
+	if err != nil {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+		return 2
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	state, err := analysis.NewState(strings.Fields(word_list))
+
+	if err != nil {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+		return 2
+	}
+
+	state.Initialize(lsp.InitializeRequestParams{
+		RootPath: analysis.FindWorkspaceRoot(dir),
+	}, logger)
+
+	// Columns are reported in characters
+	state.PositionEncoding = lsp.UTF32
+
+	if *dictionary != "" {
+		state.UpdateSettings(lsp.Settings{Proof: lsp.ProofSettings{DictionaryPath: dictionary}}, logger)
+	}
+
+	for _, err := range state.SettingsErrors() {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+	}
+
+	typos := 0
+
+	for _, path := range flags.Args() {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(stderr, "proof: %s\n", err)
+			return 2
+		}
+	}
+
+	checked := state.CheckFiles(flags.Args(), func(report analysis.FileReport) {
+		path := report.Path
+
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+
+		for _, diagnostic := range report.Diagnostics {
+			start := diagnostic.Range.Start
+			fmt.Fprintf(stdout, "%s:%d:%d: %s\n", path, start.Line+1, start.Character+1, diagnostic.Message)
+			typos++
+		}
+	}, logger)
+
+	for _, err := range state.SettingsErrors() {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+	}
+
+	fmt.Fprintf(stderr, "Checked %d files, found %d typos\n", checked, typos)
+
+	if typos > 0 {
+		return 1
+	}
+
+	return 0
+}
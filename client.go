@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"proof/analysis"
+	"proof/lsp"
+	"proof/rpc"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	clientRequestTimeout = 10 * time.Second
+	pollingInterval      = 2 * time.Second
+)
+
+// client sends requests to the lsp client and remembers what was registered
+// with it.
+type client struct {
+	writer    io.Writer
+	requests  *rpc.Requests
+	state     *analysis.State
+	scheduler *analysis.DiagnosticsScheduler
+	logger    *log.Logger
+
+	// mutex serializes configuration pulls and registrations so that older
+	// responses can't overwrite newer ones.
+	mutex               sync.Mutex
+	registrations       int
+	watchedDictionaries []string
+	watcherID           string
+}
+
+func newClient(writer io.Writer, requests *rpc.Requests, state *analysis.State, scheduler *analysis.DiagnosticsScheduler, logger *log.Logger) *client {
+	return &client{
+		writer:    writer,
+		requests:  requests,
+		state:     state,
+		scheduler: scheduler,
+		logger:    logger,
+	}
+}
+
+// pullConfiguration requests the global settings and the settings of all
+// workspace folders and open documents.
+func (c *client) pullConfiguration() {
+	c.pull(true, c.state.ConfigurationScopes())
+}
+
+// pullDocumentConfiguration requests the settings scoped to a document.
+func (c *client) pullDocumentConfiguration(uri string) {
+	c.pull(false, []string{uri})
+}
+
+func (c *client) pull(global bool, scopes []string) {
+	if !c.state.Client.SupportsConfiguration() {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	items := []lsp.ConfigurationItem{}
+
+	if global {
+		items = append(items, lsp.ConfigurationItem{Section: "proof"})
+	}
+
+	for _, scope := range scopes {
+		items = append(items, lsp.ConfigurationItem{ScopeURI: scope, Section: "proof"})
+	}
+
+	result, err := c.requests.Call("workspace/configuration", lsp.ConfigurationParams{Items: items})
+
+	if err != nil {
+		c.logger.Printf("Failed to pull configuration: %s", err)
+		return
+	}
+
+	var settings []*lsp.ProofSettings
+
+	if err := json.Unmarshal(result, &settings); err != nil || len(settings) != len(items) {
+		c.logger.Printf("Invalid configuration response: %s", result)
+		return
+	}
+
+	var globalSettings *lsp.ProofSettings
+
+	if global {
+		globalSettings = settings[0]
+		settings = settings[1:]
+
+		// A client without proof settings answers null
+		if globalSettings == nil {
+			globalSettings = &lsp.ProofSettings{}
+		}
+	}
+
+	scoped := make(map[string]lsp.ProofSettings)
+
+	for i, scope := range scopes {
+		if settings[i] != nil {
+			scoped[scope] = *settings[i]
+		}
+	}
+
+	changed := c.state.UpdateConfiguration(globalSettings, scoped, c.logger)
+	c.reportSettingsErrors()
+
+	if !changed {
+		return
+	}
+
+	c.logger.Printf("Pulled configuration for %d scopes", len(scopes))
+
+	c.watchDictionaries()
+	c.republish()
+}
+
+// republish updates the diagnostics of all open documents after settings or
+// dictionaries changed.
+func (c *client) republish() {
+	if c.state.Client.SupportsPullDiagnostics() {
+		refreshDiagnostics(c.requests, c.state, c.logger)
+	} else {
+		c.scheduler.ScheduleAll()
+	}
+}
+
+// reportSettingsErrors shows the errors found in the settings to the user,
+// like patterns which failed to compile or config files which failed to
+// parse. The invalid settings are skipped.
+func (c *client) reportSettingsErrors() {
+	for _, err := range c.state.SettingsErrors() {
+		c.logger.Printf("Skipping settings: %s", err)
+		writeResponse(c.writer, lsp.NewShowMessageNotification(lsp.ErrorMessage, "proof: "+err.Error()), c.logger)
+	}
+}
+
+// watchDictionaries registers a file watcher for the user and project
+// dictionaries so that changes made by other instances of proof or by hand
+// are picked up. The caller has to hold the mutex.
+func (c *client) watchDictionaries() {
+	if !c.state.Client.SupportsWatchedFilesRegistration() {
+		return
+	}
+
+	paths := c.state.DictionaryPaths()
+
+	if slices.Equal(paths, c.watchedDictionaries) {
+		return
+	}
+
+	if c.watcherID != "" {
+		_, err := c.requests.Call("client/unregisterCapability", lsp.UnregistrationParams{
+			Unregisterations: []lsp.Unregistration{
+				{ID: c.watcherID, Method: "workspace/didChangeWatchedFiles"},
+			},
+		})
+
+		if err != nil {
+			c.logger.Printf("Failed to unregister dictionary watcher: %s", err)
+		}
+
+		c.watcherID = ""
+	}
+
+	c.watchedDictionaries = paths
+
+	if len(paths) == 0 {
+		return
+	}
+
+	watchers := []lsp.FileSystemWatcher{}
+
+	for _, path := range paths {
+		watchers = append(watchers, lsp.FileSystemWatcher{GlobPattern: filepath.ToSlash(path)})
+	}
+
+	c.registrations++
+	id := fmt.Sprintf("proof-dictionary-%d", c.registrations)
+
+	_, err := c.requests.Call("client/registerCapability", lsp.RegistrationParams{
+		Registrations: []lsp.Registration{
+			{
+				ID:     id,
+				Method: "workspace/didChangeWatchedFiles",
+				RegisterOptions: lsp.DidChangeWatchedFilesRegistrationOptions{
+					Watchers: watchers,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		c.logger.Printf("Failed to register dictionary watcher: %s", err)
+		return
+	}
+
+	c.watcherID = id
+	c.logger.Printf("Watching dictionaries: %v", paths)
+}
+
+// pollFiles checks the dictionaries and config files for changes in an
+// interval for clients which can't watch them. It never returns.
+func (c *client) pollFiles(interval time.Duration) {
+	poller := analysis.NewFilePoller(c.state)
+
+	for {
+		if poller.Poll(c.logger) {
+			c.reportSettingsErrors()
+			c.republish()
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// watchConfigFiles registers a file watcher for the config files in the
+// workspace folders so that changes to them are picked up.
+func (c *client) watchConfigFiles() {
+	if !c.state.Client.SupportsWatchedFilesRegistration() {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	watchers := []lsp.FileSystemWatcher{}
+
+	for _, name := range analysis.ConfigFileNames() {
+		watchers = append(watchers, lsp.FileSystemWatcher{GlobPattern: "**/" + name})
+	}
+
+	c.registrations++
+
+	_, err := c.requests.Call("client/registerCapability", lsp.RegistrationParams{
+		Registrations: []lsp.Registration{
+			{
+				ID:     fmt.Sprintf("proof-config-%d", c.registrations),
+				Method: "workspace/didChangeWatchedFiles",
+				RegisterOptions: lsp.DidChangeWatchedFilesRegistrationOptions{
+					Watchers: watchers,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		c.logger.Printf("Failed to register config file watcher: %s", err)
+		return
+	}
+
+	c.logger.Print("Watching config files")
+}
+
+// updateWatchers registers watchers after settings were pushed by the
+// client.
+func (c *client) updateWatchers() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.watchDictionaries()
+}
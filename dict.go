@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"proof/analysis"
+	"slices"
+	"strings"
+)
+
+const dictUsage = `USAGE: proof dict [OPTIONS] COMMAND [ARGUMENTS]
+[COMMANDS]
+add WORD...: Add words to the dictionary
+remove WORD...: Remove words from the dictionary
+list: Print the words of the dictionary in order
+sort: Sort the words of the dictionary
+dedupe: Remove duplicate words from the dictionary
+merge [FILE...]: Keep both sides of merge conflicts in the dictionary and add
+    the words of other dictionaries
+check: Report merge conflicts, duplicates, entries which never match and words
+    which are in the built-in word list. Exits with 1 if there are any
+
+[OPTIONS]
+--file PATH: The dictionary file, like the dictionaryPath of your editor.
+    Defaults to the project dictionary of the current directory`
+
+// runDict runs the dict subcommand and returns the exit code.
+func runDict(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dict", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	path := flags.String("file", "", "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		fmt.Fprintln(stderr, dictUsage)
+		return 2
+	}
+
+	if *path == "" {
+		dir, err := os.Getwd()
+
+		if err != nil {
+			fmt.Fprintf(stderr, "proof: %s\n", err)
+			return 1
+		}
+
+		*path = analysis.ProjectDictionaryPath(dir)
+	}
+
+	command, arguments := flags.Arg(0), flags.Args()[1:]
+	lines, err := analysis.ReadDictionaryFile(*path)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+		return 1
+	}
+
+	switch command {
+	case "add", "remove":
+		var dictionary analysis.FileDictionary
+		dictionary, err = readFileDictionary(*path)
+
+		for _, word := range arguments {
+			word = strings.ToLower(word)
+
+			switch {
+			case err != nil:
+			case command == "add" && !dictionary.Contains(word):
+				err = dictionary.Add(word)
+			case command == "remove" && dictionary.Contains(word):
+				err = dictionary.Remove(word)
+			}
+		}
+
+	case "list":
+		for _, word := range analysis.DedupeWords(lines) {
+			fmt.Fprintln(stdout, word)
+		}
+
+	case "sort":
+		slices.SortFunc(lines, func(a, b string) int {
+			return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+		})
+		err = analysis.WriteDictionaryFile(*path, slices.DeleteFunc(lines, func(line string) bool { return line == "" }))
+
+	case "dedupe":
+		err = analysis.WriteDictionaryFile(*path, analysis.DedupeWords(lines))
+
+	case "merge":
+		merged := analysis.ResolveConflicts(lines)
+
+		for _, other := range arguments {
+			otherLines, readErr := analysis.ReadDictionaryFile(other)
+
+			if readErr != nil {
+				err = readErr
+				break
+			}
+
+			merged = append(merged, analysis.ResolveConflicts(otherLines)...)
+		}
+
+		if err == nil {
+			err = analysis.WriteDictionaryFile(*path, analysis.DedupeWords(merged))
+		}
+
+	case "check":
+		builtin := slices.Sorted(slices.Values(strings.Fields(word_list)))
+		problems := analysis.CheckDictionary(lines, func(word string) bool {
+			_, found := slices.BinarySearch(builtin, word)
+			return found
+		})
+
+		for _, problem := range problems {
+			fmt.Fprintf(stdout, "%s:%d: %s\n", *path, problem.Line, problem.Message)
+		}
+
+		if len(problems) > 0 {
+			return 1
+		}
+
+	default:
+		fmt.Fprintln(stderr, dictUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "proof: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// readFileDictionary reads a dictionary which doesn't have to exist yet.
+func readFileDictionary(path string) (analysis.FileDictionary, error) {
+	dictionary, err := analysis.ReadFileDictionary(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return dictionary, nil
+	}
+
+	return dictionary, err
+}
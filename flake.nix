@@ -21,7 +21,10 @@
 
         src = self;
 
-        vendorHash = "sha256-eXa3+wiLrShg8kBv3ZxYxqMfnj2wrgg7qRW3pRd2apY=";
+        # The tree-sitter grammars include C sources from directories without
+        # go files, which 'go mod vendor' leaves out.
+        proxyVendor = true;
+        vendorHash = "sha256-wr7f6dRLE8LMikd5fdOFjNEmERjTXDuE6BfZwcz3zjI=";
       };
 
       devShells.default = pkgs.mkShell {
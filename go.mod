@@ -3,7 +3,19 @@ module proof
 go 1.23
 
 require (
-	github.com/agnivade/levenshtein v1.1.1 // indirect
+	github.com/BurntSushi/toml v1.5.0
+	github.com/agnivade/levenshtein v1.1.1
+	github.com/f1monkey/spellchecker v1.1.0
+	github.com/tree-sitter/go-tree-sitter v0.25.0
+	github.com/tree-sitter/tree-sitter-go v0.25.0
+	github.com/tree-sitter/tree-sitter-html v0.23.2
+	github.com/tree-sitter/tree-sitter-java v0.23.5
+	github.com/tree-sitter/tree-sitter-json v0.24.8
+	github.com/tree-sitter/tree-sitter-python v0.25.0
+	github.com/tree-sitter/tree-sitter-rust v0.23.2
+)
+
+require (
 	github.com/f1monkey/bitmap v1.4.0 // indirect
-	github.com/f1monkey/spellchecker v1.1.0 // indirect
+	github.com/mattn/go-pointer v0.0.1 // indirect
 )
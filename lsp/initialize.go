@@ -1,13 +1,178 @@
 package lsp
 
+import "encoding/json"
+
 type InitializeRequest struct {
 	Request
 	Params InitializeRequestParams `json:"params"`
 }
 
 type InitializeRequestParams struct {
-	ProcessId  int         `json:"processId"`
-	ClientInfo *ClientInfo `json:"clientInfo"`
+	ProcessId             int                `json:"processId"`
+	ClientInfo            *ClientInfo        `json:"clientInfo"`
+	RootPath              string             `json:"rootPath,omitempty"`
+	RootURI               string             `json:"rootUri,omitempty"`
+	WorkspaceFolders      []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+	InitializationOptions json.RawMessage    `json:"initializationOptions,omitempty"`
+	Capabilities          ClientCapabilities `json:"capabilities"`
+}
+
+// InitializationSettings returns the settings sent with the
+// initializationOptions. They are accepted both on their own and nested in a
+// 'proof' section like the settings of 'workspace/didChangeConfiguration'.
+func (p InitializeRequestParams) InitializationSettings() (ProofSettings, error) {
+	if len(p.InitializationOptions) == 0 || string(p.InitializationOptions) == "null" {
+		return ProofSettings{}, nil
+	}
+
+	var section struct {
+		Proof *ProofSettings `json:"proof"`
+	}
+
+	if err := json.Unmarshal(p.InitializationOptions, &section); err != nil {
+		return ProofSettings{}, err
+	}
+
+	if section.Proof != nil {
+		return *section.Proof, nil
+	}
+
+	var settings ProofSettings
+	err := json.Unmarshal(p.InitializationOptions, &settings)
+
+	return settings, err
+}
+
+type WorkspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+type ClientCapabilities struct {
+	General      *GeneralClientCapabilities      `json:"general,omitempty"`
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+type GeneralClientCapabilities struct {
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
+}
+
+type PositionEncodingKind string
+
+const (
+	UTF8  PositionEncodingKind = "utf-8"
+	UTF16 PositionEncodingKind = "utf-16"
+	UTF32 PositionEncodingKind = "utf-32"
+)
+
+type WorkspaceClientCapabilities struct {
+	Configuration         bool                                     `json:"configuration"`
+	DidChangeWatchedFiles *DidChangeWatchedFilesClientCapabilities `json:"didChangeWatchedFiles,omitempty"`
+	Diagnostics           *DiagnosticWorkspaceClientCapabilities   `json:"diagnostics,omitempty"`
+}
+
+type DidChangeWatchedFilesClientCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration"`
+}
+
+type DiagnosticWorkspaceClientCapabilities struct {
+	RefreshSupport bool `json:"refreshSupport"`
+}
+
+type TextDocumentClientCapabilities struct {
+	CodeAction         *CodeActionClientCapabilities         `json:"codeAction,omitempty"`
+	PublishDiagnostics *PublishDiagnosticsClientCapabilities `json:"publishDiagnostics,omitempty"`
+	Diagnostic         *DiagnosticClientCapabilities         `json:"diagnostic,omitempty"`
+}
+
+type CodeActionClientCapabilities struct {
+	CodeActionLiteralSupport *CodeActionLiteralSupport `json:"codeActionLiteralSupport,omitempty"`
+	IsPreferredSupport       bool                      `json:"isPreferredSupport"`
+}
+
+type CodeActionLiteralSupport struct {
+	CodeActionKind struct {
+		ValueSet []CodeActionKind `json:"valueSet"`
+	} `json:"codeActionKind"`
+}
+
+type PublishDiagnosticsClientCapabilities struct {
+	RelatedInformation bool `json:"relatedInformation"`
+	VersionSupport     bool `json:"versionSupport"`
+}
+
+type DiagnosticClientCapabilities struct {
+	DynamicRegistration    bool `json:"dynamicRegistration"`
+	RelatedDocumentSupport bool `json:"relatedDocumentSupport"`
+}
+
+// PositionEncodings returns the position encodings offered by the client in
+// the order of its preference.
+func (c ClientCapabilities) PositionEncodings() []PositionEncodingKind {
+	if c.General == nil {
+		return nil
+	}
+
+	return c.General.PositionEncodings
+}
+
+// SupportsPullDiagnostics reports if the client requests diagnostics with
+// 'textDocument/diagnostic' instead of waiting for them to be published.
+func (c ClientCapabilities) SupportsPullDiagnostics() bool {
+	return c.TextDocument != nil && c.TextDocument.Diagnostic != nil
+}
+
+// SupportsCodeActionLiterals reports if code actions can be returned as
+// CodeAction literals. Otherwise only commands are understood by the client.
+func (c ClientCapabilities) SupportsCodeActionLiterals() bool {
+	return c.TextDocument != nil &&
+		c.TextDocument.CodeAction != nil &&
+		c.TextDocument.CodeAction.CodeActionLiteralSupport != nil
+}
+
+// SupportsPreferredCodeActions reports if the client understands the
+// isPreferred property of code actions.
+func (c ClientCapabilities) SupportsPreferredCodeActions() bool {
+	return c.SupportsCodeActionLiterals() && c.TextDocument.CodeAction.IsPreferredSupport
+}
+
+// SupportsVersionedDiagnostics reports if the client accepts the version of
+// the document with published diagnostics.
+func (c ClientCapabilities) SupportsVersionedDiagnostics() bool {
+	return c.TextDocument != nil &&
+		c.TextDocument.PublishDiagnostics != nil &&
+		c.TextDocument.PublishDiagnostics.VersionSupport
+}
+
+// SupportsRelatedInformation reports if the client shows the related
+// information of diagnostics.
+func (c ClientCapabilities) SupportsRelatedInformation() bool {
+	return c.TextDocument != nil &&
+		c.TextDocument.PublishDiagnostics != nil &&
+		c.TextDocument.PublishDiagnostics.RelatedInformation
+}
+
+// SupportsConfiguration reports if the client answers
+// 'workspace/configuration' requests.
+func (c ClientCapabilities) SupportsConfiguration() bool {
+	return c.Workspace != nil && c.Workspace.Configuration
+}
+
+// SupportsWatchedFilesRegistration reports if file watchers can be
+// registered with 'client/registerCapability'.
+func (c ClientCapabilities) SupportsWatchedFilesRegistration() bool {
+	return c.Workspace != nil &&
+		c.Workspace.DidChangeWatchedFiles != nil &&
+		c.Workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// SupportsDiagnosticRefresh reports if the client accepts
+// 'workspace/diagnostic/refresh' requests.
+func (c ClientCapabilities) SupportsDiagnosticRefresh() bool {
+	return c.Workspace != nil &&
+		c.Workspace.Diagnostics != nil &&
+		c.Workspace.Diagnostics.RefreshSupport
 }
 
 type ClientInfo struct {
@@ -26,6 +191,7 @@ type InitializeResult struct {
 }
 
 type ServerCapabilities struct {
+	PositionEncoding       PositionEncodingKind  `json:"positionEncoding,omitempty"`
 	TextDocumentSync       int                   `json:"textDocumentSync"`
 	HoverProvider          bool                  `json:"hoverProvider"`
 	CodeActionProvider     bool                  `json:"codeActionProvider"`
@@ -33,6 +199,12 @@ type ServerCapabilities struct {
 	ExecuteCommandProvider ExecuteCommandOptions `json:"executeCommandProvider"`
 }
 
+const (
+	TextDocumentSyncNone        = 0
+	TextDocumentSyncFull        = 1
+	TextDocumentSyncIncremental = 2
+)
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -49,21 +221,27 @@ type ExecuteCommandOptions struct {
 	Commands []string `json:"commands"`
 }
 
-func NewInitializeResponse(id int) InitializerResponse {
+func NewInitializeResponse(id ID, encoding PositionEncodingKind) InitializerResponse {
 	return InitializerResponse{
 		Response: CreateResponse(id),
 		Result: InitializeResult{
 			Capabilities: ServerCapabilities{
-				TextDocumentSync:   1,
+				PositionEncoding:   encoding,
+				TextDocumentSync:   TextDocumentSyncIncremental,
 				HoverProvider:      true,
 				CodeActionProvider: true,
 				DiagnosticProvider: DiagnosticOptions{
 					Identifier:            "proof",
 					InterFileDependencies: false,
-					WorkspaceDiagnostics:  false,
+					WorkspaceDiagnostics:  true,
 				},
 				ExecuteCommandProvider: ExecuteCommandOptions{
-					Commands: []string{"proof.add_to_dictionary"},
+					Commands: []string{
+						"proof.add_to_dictionary",
+						"proof.add_to_project_dictionary",
+						"proof.remove_from_dictionary",
+						"proof.undo_last_dictionary_change",
+					},
 				},
 			},
 			ServerInfo: &ServerInfo{
@@ -1,8 +1,15 @@
 package lsp
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
 type Request struct {
 	RPC    string `json:"jsonrpc"`
-	ID     int    `json:"id"`
+	ID     ID     `json:"id"`
 	Method string `json:"method"`
 
 	// We will just specify the type of the params in all the request types
@@ -12,13 +19,16 @@ type Request struct {
 
 type Response struct {
 	RPC string `json:"jsonrpc"`
-	ID  *int   `json:"id,omitempty"`
+
+	// The id of the request being answered. It is null if the id of the
+	// request could not be determined.
+	ID *ID `json:"id"`
 
 	// Result
 	// Error
 }
 
-func CreateResponse(id int) Response {
+func CreateResponse(id ID) Response {
 	return Response{
 		RPC: "2.0",
 		ID:  &id,
@@ -36,3 +46,109 @@ func CreateNotification(method string) Notification {
 		Method: method,
 	}
 }
+
+// ID identifies a request. JSON-RPC allows both integers and strings.
+type ID struct {
+	Number   int
+	Text     string
+	IsString bool
+}
+
+func IntID(number int) ID {
+	return ID{Number: number}
+}
+
+func StringID(text string) ID {
+	return ID{Text: text, IsString: true}
+}
+
+func (id ID) String() string {
+	if id.IsString {
+		return strconv.Quote(id.Text)
+	}
+
+	return strconv.Itoa(id.Number)
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id.IsString {
+		return json.Marshal(id.Text)
+	}
+
+	return json.Marshal(id.Number)
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var text string
+
+	if err := json.Unmarshal(data, &text); err == nil {
+		*id = StringID(text)
+		return nil
+	}
+
+	var number int
+
+	if err := json.Unmarshal(data, &number); err != nil {
+		return errors.New("id must be an integer or a string")
+	}
+
+	*id = IntID(number)
+
+	return nil
+}
+
+type ErrorCode int
+
+const (
+	ParseError     ErrorCode = -32700
+	InvalidRequest ErrorCode = -32600
+	MethodNotFound ErrorCode = -32601
+	InvalidParams  ErrorCode = -32602
+	InternalError  ErrorCode = -32603
+
+	ServerNotInitialized ErrorCode = -32002
+	UnknownErrorCode     ErrorCode = -32001
+	RequestFailed        ErrorCode = -32803
+	ServerCancelled      ErrorCode = -32802
+	ContentModified      ErrorCode = -32801
+	RequestCancelled     ErrorCode = -32800
+)
+
+type ResponseError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Data    any       `json:"data,omitempty"`
+}
+
+func (e ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+type ErrorResponse struct {
+	Response
+	Error ResponseError `json:"error"`
+}
+
+// NewErrorResponse creates a response for a failed request. id is nil if the
+// id of the request could not be determined.
+func NewErrorResponse(id *ID, code ErrorCode, message string) ErrorResponse {
+	return ErrorResponse{
+		Response: Response{
+			RPC: "2.0",
+			ID:  id,
+		},
+		Error: ResponseError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+type CancelRequestNotification struct {
+	Notification
+	Params CancelParams `json:"params"`
+}
+
+type CancelParams struct {
+	ID ID `json:"id"`
+}
@@ -0,0 +1,70 @@
+package lsp
+
+// ProgressToken is either an integer or a string chosen by the client.
+type ProgressToken any
+
+type ProgressNotification struct {
+	Notification
+	Params ProgressParams `json:"params"`
+}
+
+type ProgressParams struct {
+	Token ProgressToken `json:"token"`
+	Value any           `json:"value"`
+}
+
+type WorkDoneProgressBegin struct {
+	Kind        string `json:"kind"`
+	Title       string `json:"title"`
+	Cancellable bool   `json:"cancellable"`
+	Message     string `json:"message,omitempty"`
+	Percentage  *int   `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressReport struct {
+	Kind        string `json:"kind"`
+	Cancellable bool   `json:"cancellable"`
+	Message     string `json:"message,omitempty"`
+	Percentage  *int   `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+func NewProgressNotification(token ProgressToken, value any) ProgressNotification {
+	return ProgressNotification{
+		Notification: CreateNotification("$/progress"),
+		Params: ProgressParams{
+			Token: token,
+			Value: value,
+		},
+	}
+}
+
+func NewWorkDoneProgressBegin(title string, message string) WorkDoneProgressBegin {
+	percentage := 0
+
+	return WorkDoneProgressBegin{
+		Kind:       "begin",
+		Title:      title,
+		Message:    message,
+		Percentage: &percentage,
+	}
+}
+
+func NewWorkDoneProgressReport(message string, percentage int) WorkDoneProgressReport {
+	return WorkDoneProgressReport{
+		Kind:       "report",
+		Message:    message,
+		Percentage: &percentage,
+	}
+}
+
+func NewWorkDoneProgressEnd(message string) WorkDoneProgressEnd {
+	return WorkDoneProgressEnd{
+		Kind:    "end",
+		Message: message,
+	}
+}
@@ -6,10 +6,10 @@ type Shutdown struct {
 
 type ShutdownResponse struct {
 	Response
-	Result *any `json:"result,omitempty"`
+	Result *any `json:"result"`
 }
 
-func NewShutdownResponse(id int) ShutdownResponse {
+func NewShutdownResponse(id ID) ShutdownResponse {
 	return ShutdownResponse{
 		Response: CreateResponse(id),
 		Result:   nil,
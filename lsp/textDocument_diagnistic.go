@@ -6,7 +6,9 @@ type DiagnosticRequest struct {
 }
 
 type DiagnosticRequestParams struct {
-	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	Identifier       string                 `json:"identifier,omitempty"`
+	PreviousResultId string                 `json:"previousResultId,omitempty"`
 }
 
 type DocumentDiagnosticReportKind string
@@ -23,25 +25,27 @@ type DiagnosticResponse struct {
 
 type DocumentDiagnosticReport struct {
 	Kind             DocumentDiagnosticReportKind        `json:"kind"`
-	RelatedDocuments map[string]DocumentDiagnosticReport `json:"relatedDocuments"`
-	Items            *[]Diagnostic                       `json:"items"`
-	ResultId         string                              `json:"resultId"`
+	RelatedDocuments map[string]DocumentDiagnosticReport `json:"relatedDocuments,omitempty"`
+	Items            *[]Diagnostic                       `json:"items,omitempty"`
+	ResultId         string                              `json:"resultId,omitempty"`
 }
 
-func NewDiagnosticResponse(id int, kind DocumentDiagnosticReportKind, items []Diagnostic, uri string) DiagnosticResponse {
-	maybeItems := items
+func NewDocumentDiagnosticReport(kind DocumentDiagnosticReportKind, items []Diagnostic, resultId string) DocumentDiagnosticReport {
+	report := DocumentDiagnosticReport{
+		Kind:     kind,
+		ResultId: resultId,
+	}
 
-	if kind == Unchanged {
-		maybeItems = nil
+	if kind == Full {
+		report.Items = &items
 	}
 
+	return report
+}
+
+func NewDiagnosticResponse(id ID, kind DocumentDiagnosticReportKind, items []Diagnostic, resultId string) DiagnosticResponse {
 	return DiagnosticResponse{
 		Response: CreateResponse(id),
-		Result: DocumentDiagnosticReport{
-			Kind:             kind,
-			RelatedDocuments: make(map[string]DocumentDiagnosticReport),
-			Items:            &maybeItems,
-			ResultId:         uri,
-		},
+		Result:   NewDocumentDiagnosticReport(kind, items, resultId),
 	}
 }
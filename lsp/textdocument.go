@@ -31,6 +31,11 @@ type Range struct {
 	End   Position `json:"end"`
 }
 
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
 type WorkspaceEdit struct {
 	Changes map[string][]TextEdit `json:"changes"`
 }
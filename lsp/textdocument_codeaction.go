@@ -11,17 +11,45 @@ type CodeActionParams struct {
 	Context      CodeActionContext      `json:"context"`
 }
 
-type CodeActionContext struct{}
+type CodeActionContext struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Only        []CodeActionKind `json:"only,omitempty"`
+}
+
+type CodeActionKind string
 
+const (
+	QuickFix CodeActionKind = "quickfix"
+)
+
+// CodeActionResponse holds either CodeAction literals or, for clients which
+// don't support them, only Commands.
 type CodeActionResponse struct {
 	Response
-	Result []CodeAction `json:"result"`
+	Result any `json:"result"`
+}
+
+func NewCodeActionResponse(id ID, actions []CodeAction) CodeActionResponse {
+	return CodeActionResponse{
+		Response: CreateResponse(id),
+		Result:   actions,
+	}
+}
+
+func NewCommandsResponse(id ID, commands []Command) CodeActionResponse {
+	return CodeActionResponse{
+		Response: CreateResponse(id),
+		Result:   commands,
+	}
 }
 
 type CodeAction struct {
-	Title   string         `json:"title"`
-	Edit    *WorkspaceEdit `json:"edit,omitempty"`
-	Command *Command       `json:"command,omitempty"`
+	Title       string         `json:"title"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Command     *Command       `json:"command,omitempty"`
 }
 
 type Command struct {
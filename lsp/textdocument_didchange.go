@@ -10,6 +10,9 @@ type DidChangeTextDocumentParams struct {
 	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
 }
 
+// TextDocumentContentChangeEvent replaces the text in Range with Text. If
+// Range is nil Text is the full content of the document.
 type TextDocumentContentChangeEvent struct {
-	Text string `json:"text"`
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
 }
@@ -11,9 +11,29 @@ type HoverParams struct {
 
 type HoverResponse struct {
 	Response
-	Result HoverResult `json:"result"`
+	Result *HoverResult `json:"result"`
 }
 
 type HoverResult struct {
-	Contents string `json:"contents"`
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type MarkupKind string
+
+const (
+	PlainText MarkupKind = "plaintext"
+	Markdown  MarkupKind = "markdown"
+)
+
+type MarkupContent struct {
+	Kind  MarkupKind `json:"kind"`
+	Value string     `json:"value"`
+}
+
+func NewHoverResponse(id ID, result *HoverResult) HoverResponse {
+	return HoverResponse{
+		Response: CreateResponse(id),
+		Result:   result,
+	}
 }
@@ -7,14 +7,21 @@ type PublishDiagnosticsNotification struct {
 
 type PublishDiagnosticsParams struct {
 	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 }
 
 type Diagnostic struct {
-	Range    Range               `json:"range"`
-	Severity *DiagnosticSeverity `json:"severity"`
-	Source   string              `json:"source"`
-	Message  string              `json:"message"`
+	Range              Range                          `json:"range"`
+	Severity           *DiagnosticSeverity            `json:"severity"`
+	Source             string                         `json:"source"`
+	Message            string                         `json:"message"`
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type DiagnosticRelatedInformation struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
 }
 
 type DiagnosticSeverity int
@@ -26,11 +33,14 @@ const (
 	Hint        DiagnosticSeverity = 4
 )
 
-func NewPublishDiagnosticsNotification(uri string, diagnostics []Diagnostic) PublishDiagnosticsNotification {
+// NewPublishDiagnosticsNotification creates a notification publishing the
+// diagnostics of a document. The version is omitted if it is nil.
+func NewPublishDiagnosticsNotification(uri string, version *int, diagnostics []Diagnostic) PublishDiagnosticsNotification {
 	return PublishDiagnosticsNotification{
 		Notification: CreateNotification("textDocument/publishDiagnostics"),
 		Params: PublishDiagnosticsParams{
 			URI:         uri,
+			Version:     version,
 			Diagnostics: diagnostics,
 		},
 	}
@@ -0,0 +1,30 @@
+package lsp
+
+type ShowMessageNotification struct {
+	Notification
+	Params ShowMessageParams `json:"params"`
+}
+
+type ShowMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+type MessageType int
+
+const (
+	ErrorMessage   MessageType = 1
+	WarningMessage MessageType = 2
+	InfoMessage    MessageType = 3
+	LogMessage     MessageType = 4
+)
+
+func NewShowMessageNotification(messageType MessageType, message string) ShowMessageNotification {
+	return ShowMessageNotification{
+		Notification: CreateNotification("window/showMessage"),
+		Params: ShowMessageParams{
+			Type:    messageType,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package lsp
+
+type WorkspaceDiagnosticRequest struct {
+	Request
+	Params WorkspaceDiagnosticParams `json:"params"`
+}
+
+type WorkspaceDiagnosticParams struct {
+	Identifier         string             `json:"identifier,omitempty"`
+	PreviousResultIds  []PreviousResultId `json:"previousResultIds"`
+	WorkDoneToken      ProgressToken      `json:"workDoneToken,omitempty"`
+	PartialResultToken ProgressToken      `json:"partialResultToken,omitempty"`
+}
+
+type PreviousResultId struct {
+	URI   string `json:"uri"`
+	Value string `json:"value"`
+}
+
+type WorkspaceDiagnosticResponse struct {
+	Response
+	Result WorkspaceDiagnosticReport `json:"result"`
+}
+
+type WorkspaceDiagnosticReport struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// WorkspaceDiagnosticReportPartialResult is sent as the value of a
+// '$/progress' notification when the client asked for partial results.
+type WorkspaceDiagnosticReportPartialResult struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+type WorkspaceDocumentDiagnosticReport struct {
+	DocumentDiagnosticReport
+	URI string `json:"uri"`
+
+	// The version of the document the diagnostics were computed for or nil
+	// if the document is not open in the client.
+	Version *int `json:"version"`
+}
+
+func NewWorkspaceDiagnosticResponse(id ID, items []WorkspaceDocumentDiagnosticReport) WorkspaceDiagnosticResponse {
+	return WorkspaceDiagnosticResponse{
+		Response: CreateResponse(id),
+		Result: WorkspaceDiagnosticReport{
+			Items: items,
+		},
+	}
+}
@@ -13,12 +13,122 @@ type Settings struct {
 	Proof ProofSettings `json:"proof"`
 }
 
+// ProofSettings are the settings of proof as sent by the client. Fields which
+// are nil were not set by the client and fall back to the defaults or to the
+// settings of a broader scope.
 type ProofSettings struct {
-	DictionaryPath       string   `json:"dictionaryPath"`
-	AllowImplicitPlurals bool     `json:"allowImplicitPlurals"`
-	MaxErrors            int      `json:"maxErrors"`
-	MaxSuggestions       int      `json:"maxSuggestions"`
-	IgnoredWords         []string `json:"ignoredWords"`
-	ExcludedFilePatterns []string `json:"excludedFilePatterns"`
-	ExcludedFileTypes    []string `json:"excludedFileTypes"`
+	DictionaryPath *string `json:"dictionaryPath,omitempty"`
+
+	// The path of the project dictionary relative to the workspace folders
+	// or one of their parents.
+	ProjectDictionary *string `json:"projectDictionary,omitempty"`
+
+	AllowImplicitPlurals *bool    `json:"allowImplicitPlurals,omitempty"`
+	MaxErrors            *int     `json:"maxErrors,omitempty"`
+	MaxSuggestions       *int     `json:"maxSuggestions,omitempty"`
+	IgnoredWords         []string `json:"ignoredWords,omitempty"`
+
+	// Words which are always reported, even if a dictionary knows them.
+	ForbiddenWords []string `json:"forbiddenWords,omitempty"`
+
+	// Regular expressions matched against every line to skip the matched
+	// text, and against every word to accept the matched words.
+	IgnoredPatterns     []string `json:"ignoredPatterns,omitempty"`
+	IgnoredWordPatterns []string `json:"ignoredWordPatterns,omitempty"`
+
+	ExcludedFilePatterns []string `json:"excludedFilePatterns,omitempty"`
+	ExcludedFileTypes    []string `json:"excludedFileTypes,omitempty"`
+
+	// The kinds of regions (comments, strings, identifiers) checked per
+	// language identifier, with "*" for all other languages.
+	CheckedRegions map[string][]string `json:"checkedRegions,omitempty"`
+
+	// Check the code in fenced code blocks of markdown documents like
+	// documents in the language of the block.
+	CheckCodeBlocks *bool `json:"checkCodeBlocks,omitempty"`
+
+	// Whether tokens which aren't words (urls, emails, paths, uuids,
+	// hexColors, hashes and secrets) are skipped, by the name of the token.
+	// Tokens which are not set here are skipped.
+	SkippedTokens map[string]bool `json:"skippedTokens,omitempty"`
+
+	// The severity of the diagnostics: error, warning, information or hint.
+	Severity *string `json:"severity,omitempty"`
+}
+
+// Merge returns the settings with all fields set in other replaced by the
+// values from other.
+func (s ProofSettings) Merge(other ProofSettings) ProofSettings {
+	if other.DictionaryPath != nil {
+		s.DictionaryPath = other.DictionaryPath
+	}
+
+	if other.ProjectDictionary != nil {
+		s.ProjectDictionary = other.ProjectDictionary
+	}
+
+	if other.AllowImplicitPlurals != nil {
+		s.AllowImplicitPlurals = other.AllowImplicitPlurals
+	}
+
+	if other.MaxErrors != nil {
+		s.MaxErrors = other.MaxErrors
+	}
+
+	if other.MaxSuggestions != nil {
+		s.MaxSuggestions = other.MaxSuggestions
+	}
+
+	if other.IgnoredWords != nil {
+		s.IgnoredWords = other.IgnoredWords
+	}
+
+	if other.ForbiddenWords != nil {
+		s.ForbiddenWords = other.ForbiddenWords
+	}
+
+	if other.IgnoredPatterns != nil {
+		s.IgnoredPatterns = other.IgnoredPatterns
+	}
+
+	if other.IgnoredWordPatterns != nil {
+		s.IgnoredWordPatterns = other.IgnoredWordPatterns
+	}
+
+	if other.ExcludedFilePatterns != nil {
+		s.ExcludedFilePatterns = other.ExcludedFilePatterns
+	}
+
+	if other.ExcludedFileTypes != nil {
+		s.ExcludedFileTypes = other.ExcludedFileTypes
+	}
+
+	if other.CheckedRegions != nil {
+		s.CheckedRegions = other.CheckedRegions
+	}
+
+	if other.CheckCodeBlocks != nil {
+		s.CheckCodeBlocks = other.CheckCodeBlocks
+	}
+
+	if other.Severity != nil {
+		s.Severity = other.Severity
+	}
+
+	// The tokens are toggled one by one, so they are merged by name
+	if other.SkippedTokens != nil {
+		merged := map[string]bool{}
+
+		for name, skipped := range s.SkippedTokens {
+			merged[name] = skipped
+		}
+
+		for name, skipped := range other.SkippedTokens {
+			merged[name] = skipped
+		}
+
+		s.SkippedTokens = merged
+	}
+
+	return s
 }
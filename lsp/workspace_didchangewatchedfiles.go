@@ -0,0 +1,23 @@
+package lsp
+
+type DidChangeWatchedFilesNotification struct {
+	Notification
+	Params DidChangeWatchedFilesParams `json:"params"`
+}
+
+type DidChangeWatchedFilesParams struct {
+	Changes []FileEvent `json:"changes"`
+}
+
+type FileChangeType int
+
+const (
+	Created FileChangeType = 1
+	Changed FileChangeType = 2
+	Deleted FileChangeType = 3
+)
+
+type FileEvent struct {
+	URI  string         `json:"uri"`
+	Type FileChangeType `json:"type"`
+}
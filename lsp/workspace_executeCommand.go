@@ -9,3 +9,15 @@ type ExecuteCommandParams struct {
 	Command   string   `json:"command"`
 	Arguments []string `json:"arguments"`
 }
+
+type ExecuteCommandResponse struct {
+	Response
+	Result *any `json:"result"`
+}
+
+func NewExecuteCommandResponse(id ID) ExecuteCommandResponse {
+	return ExecuteCommandResponse{
+		Response: CreateResponse(id),
+		Result:   nil,
+	}
+}
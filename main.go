@@ -1,9 +1,9 @@
 package main
 
 import (
-	"bufio"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,8 +12,8 @@ import (
 	"proof/lsp"
 	"proof/rpc"
 	"strings"
-
-	"github.com/f1monkey/spellchecker"
+	"sync"
+	"time"
 )
 
 //go:embed word-list.txt
@@ -34,48 +34,123 @@ LOG_FILE: Optionally specify a file to log to
 
 [OPTIONS]
 --version(-v): Print version number
---help(-h): Print this help message`)
+--help(-h): Print this help message
+
+Run 'proof check' to check files from the command line, for example in CI,
+and 'proof dict' to manage dictionary files.`)
 		os.Exit(0)
 	}
 
+	if len(args) >= 2 && args[1] == "dict" {
+		os.Exit(runDict(args[2:], os.Stdout, os.Stderr))
+	}
+
+	if len(args) >= 2 && args[1] == "check" {
+		os.Exit(runCheck(args[2:], os.Stdout, os.Stderr))
+	}
+
 	logger := getLogger(args)
 	logger.Println("Starting proof")
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Split(rpc.Split)
+	reader := rpc.NewReader(os.Stdin)
 
-	sc, err := spellchecker.New(
-		spellchecker.DefaultAlphabet, // Allowed symbols
-		spellchecker.WithMaxErrors(2),
-		spellchecker.WithSplitter(bufio.ScanLines),
-	)
+	state, err := analysis.NewState(strings.Fields(word_list))
 
 	if err != nil {
 		panic(err)
 	}
+	writer := rpc.NewWriter(os.Stdout)
+	requests := rpc.NewRequests(writer, clientRequestTimeout)
 
-	reader := strings.NewReader(word_list)
-	sc_err := sc.AddFrom(reader)
+	scheduler := analysis.NewDiagnosticsScheduler(state, diagnosticsDelay, func(uri string, version int, diagnostics []lsp.Diagnostic) {
+		var versioned *int
 
-	if sc_err != nil {
-		panic(sc_err)
-	}
+		if state.Client.SupportsVersionedDiagnostics() {
+			versioned = &version
+		}
 
-	state := analysis.NewState(sc)
-	writer := os.Stdout
+		msg := lsp.NewPublishDiagnosticsNotification(uri, versioned, diagnostics)
+		writeResponse(writer, msg, logger)
+		logger.Printf("Sent diagnostics for %s at version %d", uri, version)
+	}, logger)
+
+	client := newClient(writer, requests, state, scheduler, logger)
+	state.OnSpellcheckerRebuilt(client.republish)
 
 	shuttingDown := false
+	pending := newPendingRequests()
+	handling := sync.WaitGroup{}
+	messages := make(chan incomingMessage, 64)
+
+	// Messages are read ahead of handling them so that '$/cancelRequest'
+	// notifications are seen before the requests they cancel are handled.
+	go func() {
+		for {
+			message := readMessage(reader)
+
+			if errors.Is(message.Err, io.EOF) || errors.Is(message.Err, io.ErrUnexpectedEOF) {
+				break
+			}
 
-	for scanner.Scan() {
-		bytes := scanner.Bytes()
+			// Responses to our own requests are handed to the waiting
+			// caller here since it may block the main loop
+			if message.Method == "" && message.ID != nil && requests.Resolve(message.Content) {
+				continue
+			}
 
-		method, content, err := rpc.DecodeMessage(bytes)
+			if message.Method == "$/cancelRequest" {
+				var notification lsp.CancelRequestNotification
+
+				if err := json.Unmarshal(message.Content, &notification); err == nil {
+					pending.cancel(notification.Params.ID)
+				}
+			} else if message.ID != nil && message.Method != "" {
+				pending.received(*message.ID)
+			}
+
+			messages <- message
+		}
+
+		close(messages)
+	}()
+
+	for message := range messages {
+		if message.Err != nil {
+			logger.Println(message.Err)
+
+			if errors.Is(message.Err, rpc.ErrInvalidContent) {
+				msg := lsp.NewErrorResponse(nil, lsp.ParseError, message.Err.Error())
+				writeResponse(writer, msg, logger)
+			} else if message.ID == nil && message.Content != nil {
+				msg := lsp.NewErrorResponse(nil, lsp.InvalidRequest, message.Err.Error())
+				writeResponse(writer, msg, logger)
+			}
 
-		if err != nil {
-			logger.Println(err)
 			continue
 		}
 
-		shouldExit, shutdownReceived := handleMessage(logger, writer, &state, method, content)
+		if message.ID != nil && concurrentMethods[message.Method] {
+			handling.Add(1)
+
+			go func() {
+				defer handling.Done()
+
+				if !isCancelled(pending, message, writer, logger) {
+					handleMessage(logger, writer, state, scheduler, client, message.Method, message.ID, message.Content)
+				}
+			}()
+
+			continue
+		}
+
+		if isCancelled(pending, message, writer, logger) {
+			continue
+		}
+
+		if message.Method == "shutdown" {
+			handling.Wait()
+		}
+
+		shouldExit, shutdownReceived := handleMessage(logger, writer, state, scheduler, client, message.Method, message.ID, message.Content)
 
 		if shouldExit {
 			break
@@ -94,11 +169,26 @@ LOG_FILE: Optionally specify a file to log to
 	}
 }
 
+// Requests which are handled concurrently. All other messages are handled in
+// the order they arrive.
+var concurrentMethods = map[string]bool{
+	"textDocument/codeAction":  true,
+	"textDocument/diagnostic":  true,
+	"textDocument/hover":       true,
+	"workspace/diagnostic":     true,
+	"workspace/executeCommand": true,
+}
+
+const diagnosticsDelay = 100 * time.Millisecond
+
 func handleMessage(
 	logger *log.Logger,
 	writer io.Writer,
 	state *analysis.State,
+	scheduler *analysis.DiagnosticsScheduler,
+	client *client,
 	method string,
+	id *lsp.ID,
 	content []byte) (bool, bool) {
 
 	switch method {
@@ -107,15 +197,19 @@ func handleMessage(
 		var request lsp.InitializeRequest
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'initialize' | %s", err)
+			replyInvalidParams(writer, id, "initialize", err, logger)
 			return false, false
 		}
 
-		logger.Printf("Connected to: %s %s",
-			request.Params.ClientInfo.Name,
-			request.Params.ClientInfo.Version)
+		if clientInfo := request.Params.ClientInfo; clientInfo != nil {
+			logger.Printf("Connected to: %s %s",
+				clientInfo.Name,
+				clientInfo.Version)
+		}
+
+		state.Initialize(request.Params, logger)
 
-		msg := lsp.NewInitializeResponse(request.ID)
+		msg := lsp.NewInitializeResponse(request.ID, state.PositionEncoding)
 		writeResponse(writer, msg, logger)
 
 		logger.Print("Sent initialize response")
@@ -123,12 +217,20 @@ func handleMessage(
 	case "initialized":
 		logger.Print("Initialized")
 
+		client.reportSettingsErrors()
+		go client.watchConfigFiles()
+		go client.updateWatchers()
+
+		if !state.Client.SupportsWatchedFilesRegistration() {
+			go client.pollFiles(pollingInterval)
+		}
+		go client.pullConfiguration()
+
 	case "shutdown":
 		var request lsp.Shutdown
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'shutdown' | %s", err)
-
+			replyInvalidParams(writer, id, "shutdown", err, logger)
 			return false, false
 		}
 
@@ -147,73 +249,127 @@ func handleMessage(
 		var request lsp.DidChangeConfigurationRequest
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'workspace/didChangeConfiguration' | %s", err)
+			replyInvalidParams(writer, id, "workspace/didChangeConfiguration", err, logger)
 			return false, false
 		}
 
 		logger.Printf("Configuration changed: %v",
 			request.Params.Settings)
 
+		// Clients supporting 'workspace/configuration' may not send the
+		// settings with the notification, so they are pulled instead
+		if state.Client.SupportsConfiguration() {
+			go client.pullConfiguration()
+			return false, false
+		}
+
 		state.UpdateSettings(request.Params.Settings, logger)
+		client.reportSettingsErrors()
+		go client.updateWatchers()
+
+		client.republish()
+
+	case "workspace/didChangeWatchedFiles":
+		var request lsp.DidChangeWatchedFilesNotification
+
+		if err := json.Unmarshal(content, &request); err != nil {
+			replyInvalidParams(writer, id, "workspace/didChangeWatchedFiles", err, logger)
+			return false, false
+		}
+
+		changed := false
+
+		for _, change := range request.Params.Changes {
+			logger.Printf("Watched file changed: %s", change.URI)
+
+			if state.ReloadDictionary(change.URI, logger) {
+				changed = true
+			}
+
+			if state.ReloadConfigFile(change.URI, logger) {
+				changed = true
+			}
+		}
+
+		client.reportSettingsErrors()
+
+		if changed {
+			client.republish()
+		}
 
 	case "workspace/executeCommand":
 		var request lsp.ExecuteCommandRequest
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'workspace/executeCommand' | %s", err)
+			replyInvalidParams(writer, id, "workspace/executeCommand", err, logger)
 			return false, false
 		}
 
 		logger.Printf("Execute command: %s",
 			request.Params.Command)
 
-		uri, diagnostics := state.ExecuteCommand(request.Params.Command, request.Params.Arguments, logger)
+		uri := state.ExecuteCommand(request.Params.Command, request.Params.Arguments, logger)
 
-		if uri != "" {
-			msg := lsp.NewPublishDiagnosticsNotification(uri, diagnostics)
-			writeResponse(writer, msg, logger)
+		writeResponse(writer, lsp.NewExecuteCommandResponse(request.ID), logger)
 
-			logger.Print("executeCommand sent diagnostics")
+		if uri != "" {
+			client.republish()
 		}
 
 	case "textDocument/didOpen":
 		var request lsp.DidOpenTextDocumentNotification
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'textDocument/didOpen' | %s", err)
+			replyInvalidParams(writer, id, "textDocument/didOpen", err, logger)
 			return false, false
 		}
 
 		logger.Printf("Opened: %s",
 			request.Params.TextDocument.URI)
 
-		diagnostics, diagnosticsDiffer := state.OpenDocument(request.Params.TextDocument, logger)
+		shouldCheck := state.OpenDocument(request.Params.TextDocument, logger)
+		client.reportSettingsErrors()
 
-		if diagnosticsDiffer {
-			msg := lsp.NewPublishDiagnosticsNotification(request.Params.TextDocument.URI, diagnostics)
-			writeResponse(writer, msg, logger)
-			logger.Print("didOpen sent diagnostics")
+		if shouldCheck && !state.Client.SupportsPullDiagnostics() {
+			scheduler.Schedule(request.Params.TextDocument.URI)
 		}
 
+		go client.pullDocumentConfiguration(request.Params.TextDocument.URI)
+
 	case "textDocument/didChange":
 		var request lsp.DidChangeTextDocumentNotification
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'textDocument/didChange' | %s", err)
+			replyInvalidParams(writer, id, "textDocument/didChange", err, logger)
 			return false, false
 		}
 
 		logger.Printf("Changed: %s",
 			request.Params.TextDocument.URI)
 
-		for _, change := range request.Params.ContentChanges {
-			diagnostics, diagnosticsDiffer := state.UpdateDocument(request.Params.TextDocument, change.Text, logger)
+		shouldCheck := state.UpdateDocument(request.Params.TextDocument, request.Params.ContentChanges, logger)
 
-			if diagnosticsDiffer {
-				msg := lsp.NewPublishDiagnosticsNotification(request.Params.TextDocument.URI, diagnostics)
-				writeResponse(writer, msg, logger)
-				logger.Print("didChange sent diagnostics")
-			}
+		if shouldCheck && !state.Client.SupportsPullDiagnostics() {
+			scheduler.Schedule(request.Params.TextDocument.URI)
+		}
+
+	case "textDocument/didClose":
+		var request lsp.DidCloseTextDocumentNotification
+
+		if err := json.Unmarshal(content, &request); err != nil {
+			replyInvalidParams(writer, id, "textDocument/didClose", err, logger)
+			return false, false
+		}
+
+		logger.Printf("Closed: %s",
+			request.Params.TextDocument.URI)
+
+		state.CloseDocument(request.Params.TextDocument.URI)
+		scheduler.Stop(request.Params.TextDocument.URI)
+
+		if !state.Client.SupportsPullDiagnostics() {
+			msg := lsp.NewPublishDiagnosticsNotification(request.Params.TextDocument.URI, nil, []lsp.Diagnostic{})
+			writeResponse(writer, msg, logger)
 		}
 
 	case "textDocument/codeAction":
@@ -221,7 +377,7 @@ func handleMessage(
 		var request lsp.CodeActionRequest
 
 		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Can't parse method 'textDocument/codeAction' | %s", err)
+			replyInvalidParams(writer, id, "textDocument/codeAction", err, logger)
 			return false, false
 		}
 
@@ -234,9 +390,109 @@ func handleMessage(
 
 		writeResponse(writer, response, logger)
 
+	case "textDocument/diagnostic":
+		var request lsp.DiagnosticRequest
+
+		if err := json.Unmarshal(content, &request); err != nil {
+			replyInvalidParams(writer, id, "textDocument/diagnostic", err, logger)
+			return false, false
+		}
+
+		logger.Printf("Diagnostic: %s",
+			request.Params.TextDocument.URI)
+
+		kind, diagnostics, resultID := state.Diagnostic(
+			request.Params.TextDocument.URI,
+			request.Params.PreviousResultId,
+			logger)
+
+		msg := lsp.NewDiagnosticResponse(request.ID, kind, diagnostics, resultID)
+		writeResponse(writer, msg, logger)
+
+		logger.Printf("Sent %s diagnostic report", kind)
+
+	case "workspace/diagnostic":
+		var request lsp.WorkspaceDiagnosticRequest
+
+		if err := json.Unmarshal(content, &request); err != nil {
+			replyInvalidParams(writer, id, "workspace/diagnostic", err, logger)
+			return false, false
+		}
+
+		logger.Print("Workspace diagnostic")
+
+		previousResultIDs := make(map[string]string)
+
+		for _, previous := range request.Params.PreviousResultIds {
+			previousResultIDs[previous.URI] = previous.Value
+		}
+
+		workDoneToken := request.Params.WorkDoneToken
+		partialResultToken := request.Params.PartialResultToken
+
+		if workDoneToken != nil {
+			begin := lsp.NewWorkDoneProgressBegin("proof", "Checking workspace")
+			writeResponse(writer, lsp.NewProgressNotification(workDoneToken, begin), logger)
+		}
+
+		items := []lsp.WorkspaceDocumentDiagnosticReport{}
+
+		state.WorkspaceDiagnostic(previousResultIDs, func(reports []lsp.WorkspaceDocumentDiagnosticReport, checked int, total int) {
+			if partialResultToken != nil {
+				partial := lsp.WorkspaceDiagnosticReportPartialResult{Items: reports}
+				writeResponse(writer, lsp.NewProgressNotification(partialResultToken, partial), logger)
+			} else {
+				items = append(items, reports...)
+			}
+
+			if workDoneToken != nil {
+				message := fmt.Sprintf("%d/%d files", checked, total)
+				report := lsp.NewWorkDoneProgressReport(message, checked*100/total)
+				writeResponse(writer, lsp.NewProgressNotification(workDoneToken, report), logger)
+			}
+		}, logger)
+
+		if workDoneToken != nil {
+			end := lsp.NewWorkDoneProgressEnd("Checked workspace")
+			writeResponse(writer, lsp.NewProgressNotification(workDoneToken, end), logger)
+		}
+
+		msg := lsp.NewWorkspaceDiagnosticResponse(request.ID, items)
+		writeResponse(writer, msg, logger)
+
+		logger.Print("Sent workspace diagnostic report")
+
+	case "textDocument/hover":
+		var request lsp.HoverTextRequest
+
+		if err := json.Unmarshal(content, &request); err != nil {
+			replyInvalidParams(writer, id, "textDocument/hover", err, logger)
+			return false, false
+		}
+
+		logger.Printf("Hover: %s",
+			request.Params.TextDocument.URI)
+
+		response := state.Hover(request, request.Params.TextDocument.URI, logger)
+
+		writeResponse(writer, response, logger)
+
+	case "$/cancelRequest":
+		// Cancellations are recorded while reading messages
+
+	case "":
+		if id != nil {
+			logger.Printf("Received response to request %s", id)
+		}
+
 	default:
 		logger.Printf("Unhandled method: %s", method)
 
+		if id != nil {
+			msg := lsp.NewErrorResponse(id, lsp.MethodNotFound, fmt.Sprintf("Method not found: %s", method))
+			writeResponse(writer, msg, logger)
+		}
+
 	}
 
 	return false, false
@@ -263,6 +519,112 @@ func getLogger(args []string) *log.Logger {
 	return log.New(log_file, "[proof]", log.Ldate|log.Ltime|log.Lshortfile)
 }
 
+type incomingMessage struct {
+	Method  string
+	Content []byte
+
+	// The id of the message or nil for notifications.
+	ID  *lsp.ID
+	Err error
+}
+
+func readMessage(reader *rpc.Reader) incomingMessage {
+	method, content, err := reader.ReadMessage()
+
+	if err != nil {
+		return incomingMessage{Err: err}
+	}
+
+	var envelope struct {
+		ID *lsp.ID `json:"id"`
+	}
+
+	if err := json.Unmarshal(content, &envelope); err != nil {
+		return incomingMessage{Method: method, Content: content, Err: err}
+	}
+
+	return incomingMessage{Method: method, Content: content, ID: envelope.ID}
+}
+
+// pendingRequests tracks requests which were read but not handled yet so
+// that they can be cancelled by the client.
+type pendingRequests struct {
+	mutex     sync.Mutex
+	cancelled map[lsp.ID]bool
+}
+
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{cancelled: make(map[lsp.ID]bool)}
+}
+
+func (p *pendingRequests) received(id lsp.ID) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cancelled[id] = false
+}
+
+func (p *pendingRequests) cancel(id lsp.ID) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.cancelled[id]; ok {
+		p.cancelled[id] = true
+	}
+}
+
+// start removes the request from the pending requests and reports if it was
+// cancelled.
+func (p *pendingRequests) start(id lsp.ID) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	cancelled := p.cancelled[id]
+	delete(p.cancelled, id)
+
+	return cancelled
+}
+
+// isCancelled replies to requests which were cancelled before they were
+// handled.
+func isCancelled(pending *pendingRequests, message incomingMessage, writer io.Writer, logger *log.Logger) bool {
+	if message.ID == nil || message.Method == "" || !pending.start(*message.ID) {
+		return false
+	}
+
+	logger.Printf("Request %s was cancelled", message.ID)
+
+	msg := lsp.NewErrorResponse(message.ID, lsp.RequestCancelled, "Request cancelled")
+	writeResponse(writer, msg, logger)
+
+	return true
+}
+
+func replyInvalidParams(writer io.Writer, id *lsp.ID, method string, err error, logger *log.Logger) {
+	logger.Printf("Can't parse method '%s' | %s", method, err)
+
+	if id != nil {
+		msg := lsp.NewErrorResponse(id, lsp.InvalidParams, err.Error())
+		writeResponse(writer, msg, logger)
+	}
+}
+
+// refreshDiagnostics asks clients using pull diagnostics to request
+// diagnostics again after something other than the document changed.
+func refreshDiagnostics(requests *rpc.Requests, state *analysis.State, logger *log.Logger) {
+	if !state.Client.SupportsDiagnosticRefresh() {
+		return
+	}
+
+	logger.Print("Sending diagnostic refresh request")
+
+	go func() {
+		if _, err := requests.Call("workspace/diagnostic/refresh", nil); err != nil {
+			logger.Printf("Diagnostic refresh failed: %s", err)
+		}
+	}()
+}
+
 func writeResponse(writer io.Writer, msg any, logger *log.Logger) {
 	reply := rpc.EncodeMessage(msg)
 	_, err := writer.Write([]byte(reply))
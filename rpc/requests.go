@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when the other side doesn't answer a request in time.
+var ErrTimeout = errors.New("request timed out")
+
+// Requests sends requests to the other side of the connection and matches
+// the responses to them by their id.
+type Requests struct {
+	writer  io.Writer
+	timeout time.Duration
+
+	mutex   sync.Mutex
+	nextID  int
+	pending map[int]chan response
+}
+
+type request struct {
+	RPC    string `json:"jsonrpc"`
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     *json.RawMessage `json:"id"`
+	Result json.RawMessage  `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewRequests(writer io.Writer, timeout time.Duration) *Requests {
+	return &Requests{
+		writer:  writer,
+		timeout: timeout,
+		pending: make(map[int]chan response),
+	}
+}
+
+// Call sends a request and waits for its response. The result is returned as
+// raw JSON to be decoded by the caller.
+func (r *Requests) Call(method string, params any) (json.RawMessage, error) {
+	r.mutex.Lock()
+	r.nextID++
+	id := r.nextID
+	channel := make(chan response, 1)
+	r.pending[id] = channel
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.pending, id)
+		r.mutex.Unlock()
+	}()
+
+	message := EncodeMessage(request{RPC: "2.0", ID: id, Method: method, Params: params})
+
+	if _, err := r.writer.Write([]byte(message)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-channel:
+		if res.Error != nil {
+			return nil, fmt.Errorf("%s failed: %s (%d)", method, res.Error.Message, res.Error.Code)
+		}
+
+		return res.Result, nil
+
+	case <-time.After(r.timeout):
+		return nil, fmt.Errorf("%w: %s", ErrTimeout, method)
+	}
+}
+
+// Resolve hands a response to the pending request with the same id. It
+// reports false if the content is not a response to one of our requests.
+func (r *Requests) Resolve(content []byte) bool {
+	var res response
+
+	if err := json.Unmarshal(content, &res); err != nil || res.ID == nil {
+		return false
+	}
+
+	var id int
+
+	if err := json.Unmarshal(*res.ID, &id); err != nil {
+		return false
+	}
+
+	r.mutex.Lock()
+	channel, ok := r.pending[id]
+	r.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	channel <- res
+
+	return true
+}
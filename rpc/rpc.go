@@ -1,11 +1,15 @@
 package rpc
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 func EncodeMessage(msg any) string {
@@ -18,6 +22,17 @@ func EncodeMessage(msg any) string {
 	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
 }
 
+// ErrInvalidContent is returned when the content of a message is not valid
+// JSON.
+var ErrInvalidContent = errors.New("invalid message content")
+
+// ErrInvalidHeader is returned when the header of a message can't be parsed.
+var ErrInvalidHeader = errors.New("invalid message header")
+
+// MaxContentLength is the largest message accepted. Larger Content-Length
+// headers are taken as corrupt instead of allocating the memory for them.
+const MaxContentLength = 64 << 20
+
 type BaseMessage struct {
 	Method string `json:"method"`
 }
@@ -29,21 +44,27 @@ func DecodeMessage(msg []byte) (string, []byte, error) {
 		return "", nil, errors.New("did not find separator")
 	}
 
-	contentLengthBytes := header[len("Content-Length: "):]
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	contentLength, err := parseHeader(string(header))
 
 	if err != nil {
 		return "", nil, err
 	}
-	_ = content
 
+	if len(content) < contentLength {
+		return "", nil, fmt.Errorf("%w: expected %d bytes of content, got %d", ErrInvalidHeader, contentLength, len(content))
+	}
+
+	return decodeContent(content[:contentLength])
+}
+
+func decodeContent(content []byte) (string, []byte, error) {
 	var baseMessage BaseMessage
 
-	if err := json.Unmarshal(content[:contentLength], &baseMessage); err != nil {
-		return "", nil, err
+	if err := json.Unmarshal(content, &baseMessage); err != nil {
+		return "", content, fmt.Errorf("%w: %s", ErrInvalidContent, err)
 	}
 
-	return baseMessage.Method, content[:contentLength], nil
+	return baseMessage.Method, content, nil
 }
 
 func Split(data []byte, _ bool) (advance int, token []byte, err error) {
@@ -53,11 +74,11 @@ func Split(data []byte, _ bool) (advance int, token []byte, err error) {
 		return 0, nil, nil
 	}
 
-	contentLengthBytes := header[len("Content-Length: "):]
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	contentLength, err := parseHeader(string(header))
 
 	if err != nil {
-		return 0, nil, err
+		// Skip the corrupt header and try again with the data after it
+		return len(header) + 4, nil, nil
 	}
 
 	if len(content) < contentLength {
@@ -68,3 +89,132 @@ func Split(data []byte, _ bool) (advance int, token []byte, err error) {
 
 	return totalLength, data[:totalLength], nil
 }
+
+var headerName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// parseHeader parses a header block of one or more 'Name: value' lines
+// separated by '\r\n' and returns the value of the required Content-Length
+// header. Header names are case-insensitive and unknown headers, such as
+// Content-Type, are ignored.
+func parseHeader(header string) (int, error) {
+	contentLength := -1
+
+	for _, line := range strings.Split(header, "\r\n") {
+		name, value, err := parseHeaderLine(line)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if !strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+
+		contentLength, err = strconv.Atoi(value)
+
+		if err != nil || contentLength < 0 {
+			return 0, fmt.Errorf("%w: bad Content-Length %q", ErrInvalidHeader, value)
+		}
+
+		if contentLength > MaxContentLength {
+			return 0, fmt.Errorf("%w: Content-Length %d exceeds the limit of %d bytes", ErrInvalidHeader, contentLength, MaxContentLength)
+		}
+	}
+
+	if contentLength == -1 {
+		return 0, fmt.Errorf("%w: missing Content-Length", ErrInvalidHeader)
+	}
+
+	return contentLength, nil
+}
+
+func parseHeaderLine(line string) (string, string, error) {
+	name, value, found := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+
+	if !found || !headerName.MatchString(name) {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	return name, strings.TrimSpace(value), nil
+}
+
+// Reader reads messages from a stream. Unlike a bufio.Scanner using Split it
+// reads messages up to MaxContentLength without a fixed buffer and it
+// recovers from corrupt messages by skipping ahead to the next Content-Length
+// header.
+type Reader struct {
+	reader *bufio.Reader
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{reader: bufio.NewReader(reader)}
+}
+
+// ReadMessage reads the next message and returns its method and content.
+// Errors wrapping ErrInvalidHeader or ErrInvalidContent only affect the
+// current message and reading can continue. io.EOF is returned when the
+// stream ends.
+func (r *Reader) ReadMessage() (string, []byte, error) {
+	contentLength, err := r.readHeader()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	content := make([]byte, contentLength)
+
+	if _, err := io.ReadFull(r.reader, content); err != nil {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+
+	return decodeContent(content)
+}
+
+func (r *Reader) readHeader() (int, error) {
+	lines := []string{}
+
+	for {
+		line, err := r.reader.ReadString('\n')
+
+		if err != nil {
+			if err == io.EOF && line == "" && len(lines) == 0 {
+				return 0, io.EOF
+			}
+
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if len(lines) == 0 {
+			// Skip anything before the start of the next message, such as
+			// the remains of a corrupt message.
+			line = line[max(indexFold(line, "content-length"), 0):]
+
+			if _, _, err := parseHeaderLine(line); err != nil {
+				continue
+			}
+		}
+
+		if line == "" {
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	return parseHeader(strings.Join(lines, "\r\n"))
+}
+
+// indexFold returns the index of the first case-insensitive occurrence of
+// substr in s or -1.
+func indexFold(s string, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -1,8 +1,12 @@
 package rpc_test
 
 import (
+	"errors"
+	"io"
 	"proof/rpc"
+	"strings"
 	"testing"
+	"time"
 )
 
 type EncodingExample struct {
@@ -34,3 +38,121 @@ func TestDecode(t *testing.T) {
 		t.Fatalf("Expected method 'hi', got %s", method)
 	}
 }
+
+func TestDecodeInvalidContent(t *testing.T) {
+	message := "Content-Length: 10\r\n\r\n{\"Method\":"
+
+	_, _, err := rpc.DecodeMessage([]byte(message))
+
+	if !errors.Is(err, rpc.ErrInvalidContent) {
+		t.Fatalf("Expected ErrInvalidContent, got %v", err)
+	}
+}
+
+func TestDecodeWithMultipleHeaders(t *testing.T) {
+	message := "content-type: application/vscode-jsonrpc; charset=utf-8\r\nCONTENT-LENGTH:15\r\n\r\n{\"Method\":\"hi\"}"
+
+	method, _, err := rpc.DecodeMessage([]byte(message))
+
+	if err != nil {
+		t.Fatalf("Error decoding message: %s", err)
+	}
+
+	if method != "hi" {
+		t.Fatalf("Expected method 'hi', got %s", method)
+	}
+}
+
+func TestReaderHandlesLargeMessages(t *testing.T) {
+	text := strings.Repeat("a", 1<<20)
+	stream := rpc.EncodeMessage(map[string]string{"method": "big", "text": text})
+
+	reader := rpc.NewReader(strings.NewReader(stream))
+	method, content, err := reader.ReadMessage()
+
+	if err != nil {
+		t.Fatalf("Error reading message: %s", err)
+	}
+
+	if method != "big" || len(content) < len(text) {
+		t.Fatalf("Expected method 'big' with %d bytes, got '%s' with %d bytes", len(text), method, len(content))
+	}
+}
+
+func TestReaderResynchronizes(t *testing.T) {
+	stream := "Content-Length: abc\r\n\r\n{\"method\":\"lost\"}" +
+		rpc.EncodeMessage(map[string]string{"method": "first"}) +
+		"Content-Length: 3\r\n\r\n{\"m" +
+		"garbage\r\n" +
+		"Content-Type: application/vscode-jsonrpc\r\n" +
+		rpc.EncodeMessage(map[string]string{"method": "second"})
+
+	reader := rpc.NewReader(strings.NewReader(stream))
+	methods := []string{}
+
+	for {
+		method, _, err := reader.ReadMessage()
+
+		if err == io.EOF {
+			break
+		}
+
+		if errors.Is(err, rpc.ErrInvalidHeader) || errors.Is(err, rpc.ErrInvalidContent) {
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		methods = append(methods, method)
+	}
+
+	if strings.Join(methods, ",") != "first,second" {
+		t.Fatalf("Expected methods 'first,second', got '%s'", strings.Join(methods, ","))
+	}
+}
+
+func TestReaderRejectsHugeContentLength(t *testing.T) {
+	stream := "Content-Length: 9999999999\r\n\r\n{\"method\":\"huge\"}" +
+		rpc.EncodeMessage(map[string]string{"method": "next"})
+
+	reader := rpc.NewReader(strings.NewReader(stream))
+
+	if _, _, err := reader.ReadMessage(); !errors.Is(err, rpc.ErrInvalidHeader) {
+		t.Fatalf("Expected the Content-Length to be rejected, got %v", err)
+	}
+
+	method, _, err := reader.ReadMessage()
+
+	if err != nil || method != "next" {
+		t.Fatalf("Expected the next message to be read, got '%s' and %v", method, err)
+	}
+}
+
+func TestRequestsResolveResponses(t *testing.T) {
+	reader, writer := io.Pipe()
+	requests := rpc.NewRequests(writer, time.Second)
+
+	go func() {
+		if _, _, err := rpc.NewReader(reader).ReadMessage(); err != nil {
+			return
+		}
+
+		requests.Resolve([]byte(`{"jsonrpc":"2.0","id":1,"result":["a"]}`))
+	}()
+
+	result, err := requests.Call("workspace/configuration", nil)
+
+	if err != nil {
+		t.Fatalf("Error calling: %s", err)
+	}
+
+	if string(result) != `["a"]` {
+		t.Fatalf("Expected result [\"a\"], got %s", result)
+	}
+
+	if requests.Resolve([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`)) {
+		t.Fatal("Expected response to unknown request to be rejected")
+	}
+}
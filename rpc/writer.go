@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"io"
+	"sync"
+)
+
+// Writer serializes writes to an underlying writer so that messages written
+// from multiple goroutines don't interleave.
+type Writer struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriter(writer io.Writer) *Writer {
+	return &Writer{writer: writer}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Write(p)
+}